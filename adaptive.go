@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ActionDamage is the damage an action was seen dealing to an opponent.
+type ActionDamage struct {
+	Rounds int
+	Dealt  float32 // the total over all rounds
+}
+
+func (d ActionDamage) Mean() float32 {
+	if d.Rounds == 0 {
+		return 0
+	}
+	return d.Dealt / float32(d.Rounds)
+}
+
+// ActionEstimates is what the adaptive strategy learned: the damage of
+// every action against every opponent, by lower case name and actionKey.
+type ActionEstimates map[string]map[string]ActionDamage
+
+// estimatesFile returns where the estimates learned for an account are
+// kept.
+func estimatesFile(account string) string {
+	return filepath.Join(historyDir(account), "actions.json")
+}
+
+// LoadActionEstimates reads the estimates saved in filename. A missing
+// file has nothing learned yet.
+func LoadActionEstimates(filename string) (ActionEstimates, error) {
+	e := make(ActionEstimates)
+	data, err := os.ReadFile(filename)
+	if errors.Is(err, os.ErrNotExist) {
+		return e, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(data, &e)
+	return e, err
+}
+
+func (e ActionEstimates) Save(filename string) error {
+	data, err := json.MarshalIndent(e, "", "\t")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(filename), 0777); err != nil {
+		return err
+	}
+	return os.WriteFile(filename, data, 0666)
+}
+
+// add records the damage an action dealt to an opponent in a round.
+func (e ActionEstimates) add(opponent, action string, dealt float32) {
+	opponent, action = strings.ToLower(opponent), actionKey(action)
+	if e[opponent] == nil {
+		e[opponent] = make(map[string]ActionDamage)
+	}
+	d := e[opponent][action]
+	d.Rounds++
+	d.Dealt += dealt
+	e[opponent][action] = d
+}
+
+// adaptiveStrategy chooses actions by the damage the bot learned they
+// deal. It first tries every available action once against an opponent,
+// then mostly uses the one with the best mean damage, exploring a random
+// one at the given rate. When no action is available it asks the
+// fallback strategy.
+type adaptiveStrategy struct {
+	estimates ActionEstimates
+	explore   float64
+	fallback  Strategy
+}
+
+func (st *adaptiveStrategy) Action(bg *Battleground, s *battleState) string {
+	if len(bg.Actions) == 0 {
+		return st.fallback.Action(bg, s)
+	}
+	actions := make([]string, 0, len(bg.Actions))
+	for name := range bg.Actions {
+		actions = append(actions, name)
+	}
+	sort.Strings(actions)
+
+	learned := st.estimates[strings.ToLower(s.Opponent)]
+	for _, action := range actions {
+		if learned[action].Rounds == 0 {
+			return action
+		}
+	}
+	if rand.Float64() < st.explore {
+		return actions[rand.Intn(len(actions))]
+	}
+	best := actions[0]
+	for _, action := range actions[1:] {
+		if learned[action].Mean() > learned[best].Mean() {
+			best = action
+		}
+	}
+	return best
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// newAPI returns the handler of the status and control API:
+//
+//	GET  /status           the status of every account
+//	POST /pause            stop starting new steps
+//	POST /resume           start steps again after a pause
+//	POST /stop             stop once the current step is done
+//	POST /mode?mode=battle switch to another mode
+//
+// Control requests apply to every account, or to the one named by the
+// account parameter, and answer with the status of the accounts they
+// applied to.
+func newAPI(bots []*bot) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/status", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" {
+			http.Error(w, "Use GET", http.StatusMethodNotAllowed)
+			return
+		}
+		writeStatus(w, bots)
+	})
+	control := func(path string, do func(b *bot, r *http.Request) error) {
+		mux.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != "POST" {
+				http.Error(w, "Use POST", http.StatusMethodNotAllowed)
+				return
+			}
+			selected := bots
+			if account := r.FormValue("account"); account != "" {
+				selected = nil
+				for _, b := range bots {
+					if strings.EqualFold(b.cnf.Name, account) {
+						selected = append(selected, b)
+					}
+				}
+				if len(selected) == 0 {
+					http.Error(w, "No account \""+account+"\"", http.StatusNotFound)
+					return
+				}
+			}
+			for _, b := range selected {
+				if err := do(b, r); err != nil {
+					http.Error(w, err.Error(), http.StatusBadRequest)
+					return
+				}
+			}
+			writeStatus(w, selected)
+		})
+	}
+	control("/pause", func(b *bot, r *http.Request) error {
+		b.Pause()
+		return nil
+	})
+	control("/resume", func(b *bot, r *http.Request) error {
+		b.Resume()
+		return nil
+	})
+	control("/stop", func(b *bot, r *http.Request) error {
+		b.Stop()
+		return nil
+	})
+	control("/mode", func(b *bot, r *http.Request) error {
+		mode, err := parseMode(r.FormValue("mode"))
+		if err != nil {
+			return err
+		}
+		b.SetMode(mode)
+		return nil
+	})
+	return mux
+}
+
+// writeStatus answers with the status of the bots as JSON.
+func writeStatus(w http.ResponseWriter, bots []*bot) {
+	statuses := make([]botStatus, len(bots))
+	for i, b := range bots {
+		statuses[i] = b.Snapshot()
+	}
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "\t")
+	enc.Encode(statuses)
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAPI(t *testing.T) {
+	zippo := newBot(NewClient(), config{Name: "zippo"}, log.New(io.Discard, "", 0), modeTrain)
+	kuro := newBot(NewClient(), config{Name: "kuro"}, log.New(io.Discard, "", 0), modeBattle)
+	ts := httptest.NewServer(newAPI([]*bot{zippo, kuro}))
+	defer ts.Close()
+
+	request := func(method, path string, wantCode int) (statuses []botStatus) {
+		t.Helper()
+		req, err := http.NewRequest(method, ts.URL+path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+		if res.StatusCode != wantCode {
+			t.Fatalf("%s %s: status %d, want %d", method, path, res.StatusCode, wantCode)
+		}
+		if wantCode == http.StatusOK {
+			if err := json.NewDecoder(res.Body).Decode(&statuses); err != nil {
+				t.Fatalf("%s %s: %v", method, path, err)
+			}
+		}
+		return
+	}
+
+	statuses := request("GET", "/status", http.StatusOK)
+	if len(statuses) != 2 || statuses[0].Account != "zippo" || statuses[0].Mode != "train" || statuses[1].Mode != "battle" {
+		t.Fatalf("GET /status = %+v", statuses)
+	}
+	request("POST", "/status", http.StatusMethodNotAllowed)
+	request("GET", "/pause", http.StatusMethodNotAllowed)
+
+	statuses = request("POST", "/pause?account=Kuro", http.StatusOK)
+	if len(statuses) != 1 || !statuses[0].Paused || zippo.Snapshot().Paused {
+		t.Fatalf("POST /pause?account=Kuro = %+v", statuses)
+	}
+	request("POST", "/resume?account=nobody", http.StatusNotFound)
+	if statuses = request("POST", "/resume", http.StatusOK); statuses[1].Paused {
+		t.Fatalf("POST /resume = %+v", statuses)
+	}
+
+	request("POST", "/mode?mode=sleep", http.StatusBadRequest)
+	statuses = request("POST", "/mode?mode=auto", http.StatusOK)
+	if zippo.Mode() != modeAuto || kuro.Mode() != modeAuto || statuses[0].Mode != "auto" {
+		t.Fatalf("POST /mode?mode=auto = %+v", statuses)
+	}
+
+	request("POST", "/stop?account=zippo", http.StatusOK)
+	if zippo.waitRunnable() || !kuro.waitRunnable() {
+		t.Fatal("POST /stop?account=zippo didn't stop only zippo")
+	}
+}
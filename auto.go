@@ -0,0 +1,91 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// foodRetryInterval is how long the auto mode waits for the ramen shop to
+// serve again after it refused.
+const foodRetryInterval = time.Hour
+
+// Things the auto mode can do in a step.
+const (
+	autoHospital = "hospital"
+	autoBattle   = "battle"
+	autoTrain    = "train"
+	autoEat      = "eat"
+	autoRest     = "rest"
+)
+
+// autoAction decides what the auto mode does next with the character in
+// the state of the profile:
+//
+//   - leaves the hospital or finishes the battle it is in,
+//   - trains when chakra and stamina are full, unless it trained enough,
+//   - battles when health is at least battleHealth percent,
+//   - eats when it isn't and the shop may serve,
+//   - or rests until the next regeneration.
+func autoAction(p Profile, battleHealth float32, train, eat bool) string {
+	switch {
+	case p.Hospitalized:
+		return autoHospital
+	case p.InBattle:
+		return autoBattle
+	case train && p.Chakra >= p.MaxChakra && p.Stamina >= p.MaxStamina:
+		return autoTrain
+	case p.MaxHealth > 0 && p.Health/p.MaxHealth*100 >= battleHealth:
+		return autoBattle
+	case eat:
+		return autoEat
+	}
+	return autoRest
+}
+
+// autoRunner returns a step of the auto mode, which reads the profile and
+// does what autoAction decides.
+func (b *bot) autoRunner() func() error {
+	var noFoodUntil time.Time
+	return func() error {
+		p, err := b.profile()
+		if err != nil {
+			return fmt.Errorf("Can't read profile: %w", err)
+		}
+		switch autoAction(p, b.cnf.AutoHealth, !b.trainDone, time.Now().After(noFoodUntil)) {
+		case autoHospital:
+			if err := b.leaveHospital(); err != nil {
+				return fmt.Errorf("Failed to leave the hospital: %w", err)
+			}
+		case autoBattle:
+			return b.battle()
+		case autoTrain:
+			_, _, err := b.train()
+			if errors.Is(err, errTargetsMet) {
+				b.log.Println(err.Error() + ", only battling from now on")
+				b.trainDone = true
+				return nil
+			}
+			if errors.Is(err, ErrTooTired) {
+				b.log.Println("Too tired to train, resting...")
+				b.rest(time.Duration(b.cnf.TrainRest) * time.Second)
+				return nil
+			}
+			return err
+		case autoEat:
+			ate, err := b.eat()
+			if err == nil && !ate {
+				noFoodUntil = time.Now().Add(foodRetryInterval)
+			}
+			return err
+		case autoRest:
+			wait := time.Duration(p.RegenTimer) * time.Second
+			if wait <= 0 {
+				wait = regenInterval
+			}
+			b.log.Printf("Waiting %s to regenerate...\n", wait)
+			b.rest(wait)
+		}
+		return nil
+	}
+}
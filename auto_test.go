@@ -0,0 +1,31 @@
+package main
+
+import "testing"
+
+func TestAutoAction(t *testing.T) {
+	var p Profile
+	p.Health, p.MaxHealth = 180, 200
+	p.Chakra, p.MaxChakra = 50, 50
+	p.Stamina, p.MaxStamina = 40, 50
+
+	tests := []struct {
+		setup      func()
+		train, eat bool
+		want       string
+	}{
+		{func() {}, true, true, autoBattle},
+		{func() { p.Stamina = 50 }, true, true, autoTrain},
+		{func() {}, false, true, autoBattle},
+		{func() { p.Health = 100 }, true, true, autoTrain},
+		{func() {}, false, true, autoEat},
+		{func() {}, false, false, autoRest},
+		{func() { p.InBattle = true }, false, false, autoBattle},
+		{func() { p.Hospitalized = true }, true, true, autoHospital},
+	}
+	for i, test := range tests {
+		test.setup()
+		if got := autoAction(p, 80, test.train, test.eat); got != test.want {
+			t.Errorf("#%d: autoAction = %q, want %q", i, got, test.want)
+		}
+	}
+}
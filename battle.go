@@ -0,0 +1,194 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// battler returns a step that fights one battle, eats and rests.
+func (b *bot) battler() func() error {
+	return func() error {
+		if err := b.battle(); err != nil {
+			return err
+		}
+		if _, err := b.eat(); err != nil {
+			return err
+		}
+		b.log.Println("Resting a while...")
+		b.rest(time.Duration(b.cnf.BattleRest) * time.Second)
+		return nil
+	}
+}
+
+// battle fights one battle and leaves the hospital if it ends there. If
+// the character is already in a battle, for example after an error in the
+// middle of one, the battle is resumed.
+func (b *bot) battle() error {
+	var opponent string
+	if b.c.Status != statusBattle {
+		b.log.Println("Entering battle...")
+		var err error
+		opponent, err = b.c.EnterBattle()
+		if err != nil {
+			return fmt.Errorf("Failed to enter battle: %w", err)
+		}
+		b.log.Printf("Fighting %s\n", opponent)
+	}
+	bg, err := b.c.Battleground()
+	if err != nil {
+		return fmt.Errorf("Failed to get battleground: %w", err)
+	}
+	if opponent == "" {
+		for name := range bg.Opponents {
+			opponent = name
+		}
+		b.log.Printf("Resuming the battle against %s\n", opponent)
+	}
+	b.setStatus("fighting %s", opponent)
+	record := BattleRecord{Start: time.Now(), BattleID: bg.ID, Opponent: opponent}
+	err = b.fight(&bg, &record)
+	if err != nil {
+		return fmt.Errorf("Failed to attack: %w", err)
+	}
+	record.End = time.Now()
+	b.battles++
+	b.log.Printf("Battle number %d done: %s\n", b.battles, describeSummary(record.Summary))
+	if err := b.history.AddBattle(record); err != nil {
+		b.log.Println("Can't save the battle to the history:", err)
+	}
+	if b.maxHealth != nil {
+		addMaxHealth(b.maxHealth, record)
+	}
+	if b.estimates != nil {
+		if err := b.estimates.Save(estimatesFile(b.cnf.Name)); err != nil {
+			b.log.Println("Can't save the learned action damage:", err)
+		}
+	}
+	if b.c.Status == statusHospitalized {
+		b.log.Println("Hospitalized after the battle")
+		if err := b.leaveHospital(); err != nil {
+			return fmt.Errorf("Failed to leave the hospital: %w", err)
+		}
+	}
+	return nil
+}
+
+// fight attacks with the actions the strategy chooses until the battle
+// is finished, adding its rounds and summary to the record.
+func (b *bot) fight(bg *Battleground, record *BattleRecord) error {
+	opponent := record.Opponent
+	bg.EstimateMaxHealth(opponent, b.opponentMaxHealth(opponent))
+	st := b.newStrategy()
+	state := battleState{
+		Opponent:            opponent,
+		Round:               1,
+		HealthChakraStamina: bg.HealthChakraStamina,
+	}
+	state.OpponentHealth = bg.OpponentHealth[strings.ToLower(opponent)]
+	b.trackBattle(&state)
+	defer b.trackBattle(nil)
+	for {
+		action := actionKey(st.Action(bg, &state))
+		round, err := bg.Attack(b.c, action, opponent)
+		var finished *BattleFinishedError
+		if errors.As(err, &finished) {
+			record.Rounds = append(record.Rounds, RoundRecord{Action: action})
+			record.Summary = finished.Summary
+			// The last round isn't shown, but it dealt what health was left,
+			// as long as the game showed it rather than it being estimated.
+			h := state.OpponentHealth
+			if finished.Summary.Outcome == outcomeWon && h.MaxHealth > 0 && !h.Estimated {
+				b.learn(opponent, action, h.Health)
+			}
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		record.Rounds = append(record.Rounds, RoundRecord{Action: action, Hits: round.Hits})
+		dealt, _ := hitDamage(round.Hits, opponent)
+		b.learn(opponent, action, dealt)
+		s := action + ":"
+		for _, hit := range round.Hits {
+			if strings.EqualFold(hit.By, opponent) {
+				s += fmt.Sprintf("\tHe hits %d\t", int(hit.Damage))
+			} else {
+				s += fmt.Sprintf("\tYou hit %d\t", int(hit.Damage))
+			}
+		}
+		b.log.Println(s)
+		state.update(action, round)
+		state.OpponentHealth = bg.OpponentHealth[strings.ToLower(opponent)]
+		b.trackBattle(&state)
+	}
+}
+
+// opponentMaxHealth estimates the max health of an opponent from the
+// battles won against it, or returns zero.
+func (b *bot) opponentMaxHealth(opponent string) float32 {
+	if b.maxHealth == nil {
+		b.maxHealth = make(map[string]float32)
+		battles, err := b.history.Battles(time.Time{}, time.Time{})
+		if err != nil {
+			b.log.Println("Can't read the battles from the history:", err)
+		}
+		for _, r := range battles {
+			addMaxHealth(b.maxHealth, r)
+		}
+	}
+	return b.maxHealth[strings.ToLower(opponent)]
+}
+
+// addMaxHealth raises the max health estimated for the opponent of a won
+// battle to the damage it was seen taking. The last round isn't shown, so
+// the estimate stays a little low.
+func addMaxHealth(estimates map[string]float32, r BattleRecord) {
+	if r.Summary.Outcome != outcomeWon {
+		return
+	}
+	var total float32
+	for _, round := range r.Rounds {
+		dealt, _ := hitDamage(round.Hits, r.Opponent)
+		total += dealt
+	}
+	if name := strings.ToLower(r.Opponent); total > estimates[name] {
+		estimates[name] = total
+	}
+}
+
+// learn adds the damage an action dealt in a round to the estimates of
+// the adaptive strategy, when it is used.
+func (b *bot) learn(opponent, action string, dealt float32) {
+	if b.estimates != nil {
+		b.estimates.add(opponent, action, dealt)
+	}
+}
+
+// trackBattle keeps the state of the battle being fought, or nil after
+// it, for the snapshot.
+func (b *bot) trackBattle(s *battleState) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if s == nil {
+		b.fighting = nil
+		return
+	}
+	b.fighting = &battleStatus{Opponent: s.Opponent, Round: s.Round, OpponentHealth: s.OpponentHealth}
+	if s.MaxHealth > 0 {
+		b.vitals = s.HealthChakraStamina
+	}
+}
+
+// describeSummary tells how a battle ended in a few words for the log.
+func describeSummary(s BattleSummary) string {
+	d := fmt.Sprintf("%s, gained %d exp and %d ryo", outcomeNames[s.Outcome], s.Exp, s.Ryo)
+	if len(s.Items) > 0 {
+		d += ", found " + strings.Join(s.Items, ", ")
+	}
+	if s.MaxHealth > 0 {
+		d += fmt.Sprintf(", health %d/%d", int(s.Health), int(s.MaxHealth))
+	}
+	return d
+}
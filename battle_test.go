@@ -0,0 +1,92 @@
+package main
+
+import (
+	"io"
+	"log"
+	"testing"
+	"time"
+)
+
+func TestFight(t *testing.T) {
+	s := newFakeServer(t)
+	s.OpponentHealth = true
+	cnf := config{Name: s.Name, ActionSeq: []string{"Wooden Staff"}}
+	b := newBot(s.loggedInClient(), cnf, log.New(io.Discard, "", 0), modeBattle)
+	b.estimates = make(ActionEstimates)
+
+	opponent, err := b.c.EnterBattle()
+	if err != nil {
+		t.Fatal("EnterBattle:", err)
+	}
+	bg, err := b.c.Battleground()
+	if err != nil {
+		t.Fatal("Battleground:", err)
+	}
+	record := BattleRecord{Opponent: opponent}
+	if err := b.fight(&bg, &record); err != nil {
+		t.Fatal("fight:", err)
+	}
+	if len(record.Rounds) != s.Rounds {
+		t.Fatalf("recorded %d rounds, want %d", len(record.Rounds), s.Rounds)
+	}
+	for i, round := range record.Rounds {
+		if round.Action != "wooden staff" {
+			t.Errorf("round %d action = %q, want wooden staff", i+1, round.Action)
+		}
+	}
+	// Every round is learned, the last one by the health that was left.
+	if d := b.estimates["wolf cub"]["wooden staff"]; d.Rounds != 3 || d.Dealt != 23.5+23.5+40.5 {
+		t.Errorf("wooden staff estimate = %+v, want 3 rounds dealing 87.5", d)
+	}
+}
+
+func TestFightEstimatesOpponentHealth(t *testing.T) {
+	s := newFakeServer(t)
+	r, err := parseRule("opponent < 50% -> Attack")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cnf := config{Name: s.Name, ActionSeq: []string{"Wooden Staff"}, Rules: []rule{r}}
+	b := newBot(s.loggedInClient(), cnf, log.New(io.Discard, "", 0), modeBattle)
+	b.history = &History{Dir: t.TempDir()}
+	b.estimates = make(ActionEstimates)
+	won := BattleRecord{
+		Start:    time.Now(),
+		Opponent: "Wolf Cub",
+		Rounds:   []RoundRecord{{Action: "attack", Hits: []BattleHit{{Damage: 80, By: "zippo", To: "Wolf Cub"}}}},
+		Summary:  BattleSummary{Outcome: outcomeWon},
+	}
+	if err := b.history.AddBattle(won); err != nil {
+		t.Fatal("AddBattle:", err)
+	}
+
+	opponent, err := b.c.EnterBattle()
+	if err != nil {
+		t.Fatal("EnterBattle:", err)
+	}
+	bg, err := b.c.Battleground()
+	if err != nil {
+		t.Fatal("Battleground:", err)
+	}
+	record := BattleRecord{Opponent: opponent}
+	if err := b.fight(&bg, &record); err != nil {
+		t.Fatal("fight:", err)
+	}
+	// The game never shows the health, so it is estimated at 80, the most
+	// damage dealt in a won battle, and falls below half after 2 rounds.
+	var actions []string
+	for _, round := range record.Rounds {
+		actions = append(actions, round.Action)
+	}
+	if len(actions) != 3 || actions[1] != "wooden staff" || actions[2] != "attack" {
+		t.Errorf("actions = %q, want wooden staff twice, then attack", actions)
+	}
+	// The health left before the last round is only estimated, so the
+	// damage of the finishing attack isn't learned.
+	if d := b.estimates["wolf cub"]["attack"]; d.Rounds != 0 {
+		t.Errorf("attack estimate = %+v, want nothing learned", d)
+	}
+	if d := b.estimates["wolf cub"]["wooden staff"]; d.Rounds != 2 || d.Mean() != 23.5 {
+		t.Errorf("wooden staff estimate = %+v, want 2 rounds of 23.5", d)
+	}
+}
@@ -0,0 +1,473 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// rankCheckInterval is how often the rank is re-read from the profile.
+const rankCheckInterval = 30 * time.Minute
+
+// promptMu keeps the accounts from asking for captchas at the same time.
+var promptMu sync.Mutex
+
+// bot plays a single account with its own client, configuration and log.
+// Its mode can be switched, and it can be paused, resumed and stopped,
+// from other goroutines.
+type bot struct {
+	c         *Client
+	cnf       config
+	log       *log.Logger
+	history   *History
+	estimates ActionEstimates
+	backoff   func(failures int) time.Duration // how long the supervisor waits to retry
+
+	rank        int
+	rankChecked time.Time
+	battles     int                // fought since the bot started
+	nstat       int                // the next stat of the train sequence
+	trainDone   bool               // whether the training targets are met, in auto mode
+	cost        *trainCost         // of a single training, once known
+	maxHealth   map[string]float32 // of opponents, estimated from won battles
+	steps       map[int]func() error
+
+	mu        sync.Mutex
+	changed   *sync.Cond // broadcast when the controls below change
+	mode      int
+	paused    bool
+	stopped   bool
+	interrupt chan struct{} // cuts a rest short
+	started   time.Time
+	status    string
+	vitals    HealthChakraStamina
+	fighting  *battleStatus
+	lastTrain *TrainRecord
+}
+
+// battleStatus is the state of the battle a bot is fighting.
+type battleStatus struct {
+	Opponent       string
+	Round          int
+	OpponentHealth OpponentHealth
+}
+
+func newBot(c *Client, cnf config, logger *log.Logger, mode int) *bot {
+	b := &bot{
+		c:         c,
+		cnf:       cnf,
+		log:       logger,
+		mode:      mode,
+		history:   &History{Dir: historyDir(cnf.Name)},
+		rank:      rankUnknown,
+		steps:     make(map[int]func() error),
+		backoff:   backoff,
+		interrupt: make(chan struct{}, 1),
+	}
+	b.changed = sync.NewCond(&b.mu)
+	return b
+}
+
+// setStatus sets the few words Status returns.
+func (b *bot) setStatus(format string, v ...interface{}) {
+	b.mu.Lock()
+	b.status = fmt.Sprintf(format, v...)
+	b.mu.Unlock()
+}
+
+// Status tells what the bot is doing in a few words.
+func (b *bot) Status() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.status
+}
+
+// botStatus is a snapshot of a bot's state.
+type botStatus struct {
+	Account   string
+	Mode      string
+	Status    string
+	Paused    bool
+	Stopped   bool
+	Uptime    string
+	Vitals    HealthChakraStamina // as last read
+	Battle    *battleStatus       `json:",omitempty"`
+	LastTrain *TrainRecord        `json:",omitempty"`
+}
+
+func (b *bot) Snapshot() botStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	s := botStatus{
+		Account:   b.cnf.Name,
+		Mode:      modeNames[b.mode],
+		Status:    b.status,
+		Paused:    b.paused,
+		Stopped:   b.stopped,
+		Vitals:    b.vitals,
+		Battle:    b.fighting,
+		LastTrain: b.lastTrain,
+	}
+	if !b.started.IsZero() {
+		s.Uptime = time.Since(b.started).Round(time.Second).String()
+	}
+	return s
+}
+
+func (b *bot) Mode() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.mode
+}
+
+// SetMode switches the bot to another mode from its next step, cutting
+// its rest short.
+func (b *bot) SetMode(mode int) {
+	var changed bool
+	b.control(func() {
+		changed = b.mode != mode
+		b.mode = mode
+	})
+	if changed {
+		b.wake()
+	}
+}
+
+// Pause keeps the bot from starting another step until Resume.
+func (b *bot) Pause() {
+	b.control(func() { b.paused = true })
+}
+
+func (b *bot) Resume() {
+	b.control(func() { b.paused = false })
+}
+
+// Stop makes the bot return from run once its current step is done,
+// cutting its rest short.
+func (b *bot) Stop() {
+	b.control(func() { b.stopped = true })
+	b.wake()
+}
+
+func (b *bot) control(change func()) {
+	b.mu.Lock()
+	change()
+	b.mu.Unlock()
+	b.changed.Broadcast()
+}
+
+func (b *bot) wake() {
+	select {
+	case b.interrupt <- struct{}{}:
+	default:
+	}
+}
+
+// clearWake forgets the switches made before a step starts, so they don't
+// cut short a rest of the step.
+func (b *bot) clearWake() {
+	select {
+	case <-b.interrupt:
+	default:
+	}
+}
+
+// waitRunnable blocks while the bot is paused, and returns false once it
+// is stopped.
+func (b *bot) waitRunnable() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for b.paused && !b.stopped {
+		b.changed.Wait()
+	}
+	return !b.stopped
+}
+
+func (b *bot) isStopped() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.stopped
+}
+
+// profile reads the profile, keeping its health, chakra and stamina for
+// the snapshot.
+func (b *bot) profile() (Profile, error) {
+	p, err := b.c.Profile()
+	if err == nil {
+		b.mu.Lock()
+		b.vitals = p.HealthChakraStamina
+		b.mu.Unlock()
+	}
+	return p, err
+}
+
+// run logs in and plays until it is stopped, done or gives up.
+func (b *bot) run() error {
+	b.mu.Lock()
+	b.started = time.Now()
+	b.mu.Unlock()
+	b.setStatus("logging in")
+	if err := b.login(); err != nil {
+		return err
+	}
+	b.c.Relogin = b.relogin
+	b.log.Printf("Logged in as %s with PHPSESSID = %s\n", b.cnf.Name, b.c.PSID)
+	if err := b.wakeUp(); err != nil {
+		return err
+	}
+	if b.cnf.Strategy == "adaptive" {
+		var err error
+		b.estimates, err = LoadActionEstimates(estimatesFile(b.cnf.Name))
+		if err != nil {
+			return fmt.Errorf("Can't load the learned action damage: %w", err)
+		}
+	}
+
+	if err := b.updateRank(); err != nil {
+		return fmt.Errorf("Can't read rank: %w", err)
+	}
+	b.log.Printf("Rank is %s\n", rankNames[b.rank])
+
+	err := b.supervise(b.banking(b.step))
+	b.setStatus("stopped")
+	return err
+}
+
+// step runs a step of the bot's current mode. Every mode keeps its step,
+// and the state the step keeps, when the mode is switched.
+func (b *bot) step() error {
+	mode := b.Mode()
+	step, ok := b.steps[mode]
+	if !ok {
+		switch mode {
+		case modeTrain:
+			step = b.trainer()
+		case modeBattle:
+			step = b.battler()
+		case modeErrands:
+			step = b.errandsRunner()
+		case modeAuto:
+			step = b.autoRunner()
+		}
+		b.steps[mode] = step
+	}
+	return step()
+}
+
+// updateRank reads the rank from the profile, unless the configuration
+// overrides it, and logs promotions.
+func (b *bot) updateRank() error {
+	b.rankChecked = time.Now()
+	if b.cnf.Rank != rankUnknown {
+		b.rank = b.cnf.Rank
+		return nil
+	}
+	p, err := b.profile()
+	if err != nil {
+		return err
+	}
+	r, err := ParseRank(p.Rank)
+	if err != nil {
+		return err
+	}
+	if b.rank != rankUnknown && r != b.rank {
+		b.log.Printf("Promoted from %s to %s\n", rankNames[b.rank], rankNames[r])
+	}
+	b.rank = r
+	return nil
+}
+
+// login logs in with the -psid flag, the saved session or, when neither
+// is logged in, a captcha solved by the user. The session is then saved
+// for the next run.
+func (b *bot) login() error {
+	if *psid != "" {
+		if err := b.c.Resume(Session{Account: b.cnf.Name, PSID: *psid, LoginTime: time.Now()}); err != nil {
+			return fmt.Errorf("PHPSESSID %s is not logged in: %w", *psid, err)
+		}
+	} else if s, err := LoadSession(sessionFile(b.cnf.Name)); err == nil && b.c.Resume(s) == nil {
+		b.log.Printf("Resumed the session logged in at %s\n", s.LoginTime.Format("01.02.2006 15:04"))
+		return nil
+	} else if err := b.loginWithCaptcha(); err != nil {
+		return err
+	}
+	if err := b.c.Session().Save(sessionFile(b.cnf.Name)); err != nil {
+		b.log.Println("Can't save the session:", err)
+	}
+	return nil
+}
+
+// relogin logs in again with a captcha after the session expired, which
+// re-syncs the state the bot lost track of meanwhile.
+func (b *bot) relogin() error {
+	b.log.Println("The session expired")
+	b.c.LoggedIn = false
+	if err := b.loginWithCaptcha(); err != nil {
+		return err
+	}
+	if err := b.c.Session().Save(sessionFile(b.cnf.Name)); err != nil {
+		b.log.Println("Can't save the session:", err)
+	}
+	return nil
+}
+
+func (b *bot) loginWithCaptcha() error {
+	b.setStatus("waiting for a captcha")
+	promptMu.Lock()
+	defer promptMu.Unlock()
+	b.log.Println("Ninbot is logging in")
+	url, err := b.c.CaptchaURL(b.cnf.Name, b.cnf.Pass)
+	if err != nil {
+		return fmt.Errorf("Can't get captcha URL: %w", err)
+	}
+	code, err := prompter.Prompt(url)
+	if err != nil {
+		return err
+	}
+	success, err := b.c.Login(code, b.cnf.Name, b.cnf.Pass)
+	if err != nil {
+		return fmt.Errorf("Can't login: %w", err)
+	}
+	if !success {
+		return errors.New("Name, password or captcha proof code are wrong.")
+	}
+	if _, err := b.c.Sync(); err != nil {
+		return fmt.Errorf("Can't sync state: %w", err)
+	}
+	return nil
+}
+
+// wakeUp wakes the character up if it is asleep, as it may be after
+// regenerating between sessions.
+func (b *bot) wakeUp() error {
+	if b.c.Status != statusAsleep {
+		return nil
+	}
+	woke, err := b.c.Wake()
+	if err != nil {
+		return fmt.Errorf("Can't wake up: %w", err)
+	}
+	if !woke {
+		return errors.New("Can't wake up")
+	}
+	b.log.Println("Woke up")
+	return nil
+}
+
+// leaveHospital pays for healing or waits until the character is released
+// from the hospital, as configured.
+func (b *bot) leaveHospital() error {
+	for {
+		h, err := b.c.Hospital()
+		if err != nil {
+			return err
+		}
+		if !h.Hospitalized {
+			b.log.Println("Released from the hospital")
+			return nil
+		}
+		if b.cnf.HospitalPay {
+			healed, err := b.c.Heal()
+			if err != nil {
+				return err
+			}
+			if healed {
+				b.log.Printf("Paid %d ryo to be healed\n", h.Price)
+				return nil
+			}
+			b.log.Println("Can't pay for healing")
+		}
+		wait := time.Duration(h.Timer) * time.Second
+		b.log.Printf("Hospitalized, waiting %s to be released...\n", wait)
+		b.setStatus("hospitalized until %s", time.Now().Add(wait).Format("15:04:05"))
+		if !b.sleep(wait) {
+			return errInterrupted
+		}
+	}
+}
+
+// errInterrupted is returned by steps whose wait was cut short.
+var errInterrupted = errors.New("Interrupted")
+
+// sleep sleeps for the given duration plus up to two random seconds, and
+// returns false if it was cut short by a switch of mode or a stop made
+// since the step started.
+func (b *bot) sleep(d time.Duration) bool {
+	if b.isStopped() {
+		return false
+	}
+	t := time.NewTimer(d + time.Duration(rand.Int63n(int64(2*time.Second))))
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return true
+	case <-b.interrupt:
+		return false
+	}
+}
+
+// rest sets the bot's status to resting and sleeps.
+func (b *bot) rest(d time.Duration) {
+	b.setStatus("resting until %s", time.Now().Add(d).Format("15:04:05"))
+	b.sleep(d)
+}
+
+// banking wraps a step to deposit the money above the configured amount
+// after every battle, or every configured interval.
+func (b *bot) banking(step func() error) func() error {
+	if b.cnf.BankKeep < 0 {
+		return step
+	}
+	var deposited time.Time
+	return func() error {
+		battles := b.battles
+		if err := step(); err != nil {
+			return err
+		}
+		due := b.battles > battles
+		if b.cnf.BankInterval > 0 {
+			due = time.Since(deposited) >= b.cnf.BankInterval
+		}
+		if !due {
+			return nil
+		}
+		deposited = time.Now()
+		return b.deposit()
+	}
+}
+
+// deposit banks the money in pocket above the configured amount.
+func (b *bot) deposit() error {
+	bank, err := b.c.Bank()
+	if err != nil {
+		return fmt.Errorf("Can't read bank: %w", err)
+	}
+	amount := bank.Money - b.cnf.BankKeep
+	if amount <= 0 {
+		return nil
+	}
+	bank, err = b.c.Deposit(amount)
+	if err != nil {
+		return fmt.Errorf("Can't deposit: %w", err)
+	}
+	b.log.Printf("Deposited %d ryo, %d in the bank\n", amount, bank.BankedMoney)
+	return nil
+}
+
+// errandsRunner returns a step that runs as many errands as possible and
+// rests.
+func (b *bot) errandsRunner() func() error {
+	return func() error {
+		b.setStatus("running errands")
+		res, err := b.c.RunErrands(-1)
+		if err != nil {
+			return fmt.Errorf("Can't run errands: %w", err)
+		}
+		b.log.Printf("Errands earned %d ryo for %f stamina, now resting...\n", res.MoneyEarned, res.SpentStamina)
+		b.rest(time.Duration(b.cnf.ErrandsRest) * time.Second)
+		return nil
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"io"
+	"log"
+	"testing"
+	"time"
+)
+
+func TestBotSleep(t *testing.T) {
+	b := newBot(NewClient(), config{Name: "zippo"}, log.New(io.Discard, "", 0), modeTrain)
+
+	// A switch made earlier in a step cuts the step's rest short.
+	b.SetMode(modeBattle)
+	if b.sleep(time.Hour) {
+		t.Error("sleep wasn't cut short by a switch made before it")
+	}
+
+	// A switch made in an earlier step, or to the same mode, doesn't.
+	b.SetMode(modeTrain)
+	var slept bool
+	b.supervise(func() error {
+		b.SetMode(modeTrain)
+		slept = b.sleep(0)
+		b.Stop()
+		return nil
+	})
+	if !slept {
+		t.Error("sleep was cut short by a switch made in an earlier step")
+	}
+
+	b = newBot(NewClient(), config{Name: "zippo"}, log.New(io.Discard, "", 0), modeTrain)
+	done := make(chan bool)
+	go func() { done <- b.sleep(time.Hour) }()
+	time.Sleep(10 * time.Millisecond)
+	b.SetMode(modeBattle)
+	select {
+	case slept := <-done:
+		if slept {
+			t.Error("sleep wasn't cut short by a switch of mode")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("sleep wasn't cut short by a switch of mode")
+	}
+
+	b.Stop()
+	if b.sleep(time.Hour) {
+		t.Error("sleep after a stop wasn't cut short")
+	}
+}
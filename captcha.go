@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// CaptchaPrompter hands the login captcha over to a human and returns the
+// proof code they got by solving it.
+type CaptchaPrompter interface {
+	Prompt(captchaURL string) (proofCode string, err error)
+}
+
+// StdinPrompter prints the captcha URL and reads the proof code from In.
+type StdinPrompter struct {
+	In io.Reader
+}
+
+func (p StdinPrompter) Prompt(captchaURL string) (string, error) {
+	log.Println("Open the following url, solve it and paste the resulting code:", captchaURL)
+	return readProofCode(p.In)
+}
+
+// BrowserPrompter opens the captcha in the desktop's browser and reads the
+// proof code from In.
+type BrowserPrompter struct {
+	In io.Reader
+}
+
+func (p BrowserPrompter) Prompt(captchaURL string) (string, error) {
+	log.Println("A captcha window will popup, solve it and paste the resulting code")
+	if err := openBrowser(captchaURL); err != nil {
+		return "", fmt.Errorf("Can't open the captcha webpage with a browser: %w", err)
+	}
+	return readProofCode(p.In)
+}
+
+func readProofCode(in io.Reader) (string, error) {
+	var code string
+	if _, err := fmt.Fscanln(in, &code); err != nil {
+		return "", fmt.Errorf("Can't read the proof code: %w", err)
+	}
+	return code, nil
+}
+
+func openBrowser(url string) error {
+	switch runtime.GOOS {
+	case "windows":
+		return exec.Command("cmd", "/c", "start", url).Run()
+	case "darwin":
+		return exec.Command("open", url).Run()
+	}
+	return exec.Command("xdg-open", url).Run()
+}
+
+// WebPrompter serves a page on Addr that shows the captcha and accepts the
+// proof code, for when ninbot runs on a machine without a desktop.
+type WebPrompter struct {
+	Addr string
+
+	// Ready, when set, is called with the page's URL once it is served.
+	// By default the URL is logged.
+	Ready func(pageURL string)
+}
+
+var captchaPage = template.Must(template.New("captcha").Parse(`<!DOCTYPE html>
+<html>
+<head><title>ninbot captcha</title></head>
+<body>
+<p>Solve the captcha, then paste the resulting code below.
+If it doesn't show, <a href="{{.}}" target="_blank">open it directly</a>.</p>
+<iframe src="{{.}}" width="500" height="300" frameborder="0"></iframe>
+<form method="post">
+<input type="text" name="code" size="60" autofocus>
+<input type="submit" value="Log in">
+</form>
+</body>
+</html>
+`))
+
+func (p WebPrompter) Prompt(captchaURL string) (string, error) {
+	l, err := net.Listen("tcp", p.Addr)
+	if err != nil {
+		return "", err
+	}
+	codes := make(chan string, 1)
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "POST" {
+			if code := strings.TrimSpace(r.FormValue("code")); code != "" {
+				select {
+				case codes <- code:
+				default:
+				}
+				fmt.Fprintln(w, "Thanks, ninbot is logging in.")
+				return
+			}
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		captchaPage.Execute(w, captchaURL)
+	})}
+	go srv.Serve(l)
+	defer srv.Shutdown(context.Background())
+
+	pageURL := "http://" + l.Addr().String() + "/"
+	if p.Ready != nil {
+		p.Ready(pageURL)
+	} else {
+		log.Println("Open the following url to solve the captcha:", pageURL)
+	}
+	return <-codes, nil
+}
+
+// newCaptchaPrompter returns the prompter selected with the -captcha flag.
+func newCaptchaPrompter(kind, addr string) (CaptchaPrompter, error) {
+	switch strings.ToLower(kind) {
+	case "stdin":
+		return StdinPrompter{In: os.Stdin}, nil
+	case "browser":
+		return BrowserPrompter{In: os.Stdin}, nil
+	case "web":
+		return WebPrompter{Addr: addr}, nil
+	}
+	return nil, errors.New("Invalid captcha prompter \"" + kind + "\"")
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestStdinPrompter(t *testing.T) {
+	p := StdinPrompter{In: strings.NewReader("03AHJ_proof\n")}
+	code, err := p.Prompt("http://www.google.com/recaptcha/api/noscript?k=6LcTestKey")
+	if err != nil || code != "03AHJ_proof" {
+		t.Errorf("Prompt = %q, %v; want 03AHJ_proof", code, err)
+	}
+	if _, err := (StdinPrompter{In: strings.NewReader("")}).Prompt("http://example.com"); err == nil {
+		t.Error("Prompt without input succeeded")
+	}
+}
+
+func TestWebPrompter(t *testing.T) {
+	const captchaURL = "http://www.google.com/recaptcha/api/noscript?k=6LcTestKey"
+	errc := make(chan error, 1)
+	p := WebPrompter{
+		Addr: "127.0.0.1:0",
+		Ready: func(pageURL string) {
+			go func() {
+				resp, err := http.Get(pageURL)
+				if err != nil {
+					errc <- err
+					return
+				}
+				resp.Body.Close()
+				resp, err = http.PostForm(pageURL, url.Values{"code": {" 03AHJ_proof "}})
+				if err == nil {
+					resp.Body.Close()
+				}
+				errc <- err
+			}()
+		},
+	}
+	code, err := p.Prompt(captchaURL)
+	if err != nil || code != "03AHJ_proof" {
+		t.Errorf("Prompt = %q, %v; want 03AHJ_proof", code, err)
+	}
+	if err := <-errc; err != nil {
+		t.Error("posting the proof code:", err)
+	}
+}
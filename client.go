@@ -2,42 +2,124 @@ package main
 
 import (
 	"bytes"
-	"io/ioutil"
-	"http"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
 	"strconv"
-	"url"
 	"strings"
+	"sync"
 	"time"
-	"os"
-	"fmt"
 )
 
 var (
-	ErrNotLoggedIn      = os.NewError("Not logged in")
-	ErrNotInBattle      = os.NewError("Not in battle")
-	ErrNotAwake         = os.NewError("Not awake")
-	ErrBattleFinished   = os.NewError("Battle is finished")
+	ErrNotLoggedIn     = errors.New("Not logged in")
+	ErrNotInBattle     = errors.New("Not in battle")
+	ErrNotAwake        = errors.New("Not awake")
+	ErrNotAsleep       = errors.New("Not asleep")
+	ErrHospitalized    = errors.New("Hospitalized")
+	ErrNotHospitalized = errors.New("Not hospitalized")
+	ErrSessionExpired  = errors.New("Session expired")
+	ErrBattleFinished  = errors.New("Battle is finished")
+	ErrTooTired        = errors.New("Too tired to train")
 )
 
 type Battleground BattlegroundPage
 type BattleRound BattleRoundPage
 type TrainResult TrainResultPage
+type Profile ProfileWithStatsPage
+type ErrandsResult ErrandsResultPage
+type Hospital HospitalPage
+type BattleSummary BattleSummaryPage
+type Bank BankPage
+type RamenMenu RamenMenuPage
+
+// BattleFinishedError is returned by Attack when the battle is over. It
+// matches ErrBattleFinished with errors.Is.
+type BattleFinishedError struct {
+	Summary BattleSummary
+}
+
+func (e *BattleFinishedError) Error() string {
+	return ErrBattleFinished.Error()
+}
+
+func (e *BattleFinishedError) Is(target error) bool {
+	return target == ErrBattleFinished
+}
+
+// DefaultBaseURL is the address of the game.
+const DefaultBaseURL = "http://www.theninja-rpg.com"
 
 type Client struct {
-	hc       *http.Client
-	PSID     string // the PHPSESSID generated by logging in
-	LoggedIn bool
-	Status   int
+	hc        *http.Client
+	BaseURL   string // the address requests are sent to, without a trailing slash
+	PSID      string // the PHPSESSID generated by logging in
+	Account   string // the name logged in with
+	LoginTime time.Time
+	LoggedIn  bool
+	Status    int
+
+	// Relogin, when set, is called to log in again after the session
+	// expired, before retrying the request that found out.
+	Relogin func() error
+
+	// RecordDir, when set, is a directory every downloaded page is saved
+	// to, so pages from real sessions can be turned into test fixtures.
+	RecordDir string
+
+	// Budget, when set, spaces out the requests of this and every other
+	// client sharing it.
+	Budget *Budget
+}
+
+// Budget spaces out requests so that several clients together don't send
+// the server more than one request per Interval.
+type Budget struct {
+	Interval time.Duration
+
+	mu   sync.Mutex
+	next time.Time // when the next request may be sent
+}
+
+// Wait blocks until a request may be sent.
+func (b *Budget) Wait() {
+	b.mu.Lock()
+	now := time.Now()
+	if b.next.Before(now) {
+		b.next = now
+	}
+	wait := b.next.Sub(now)
+	b.next = b.next.Add(b.Interval)
+	b.mu.Unlock()
+	time.Sleep(wait)
 }
 
 func NewClient() *Client {
 	return &Client{
-		hc:   &http.Client{},
+		hc:      &http.Client{CheckRedirect: noPostRedirect},
+		BaseURL: DefaultBaseURL,
+	}
+}
+
+// noPostRedirect keeps POST responses unfollowed, so callers like Login
+// can inspect the Location header the game answers with.
+func noPostRedirect(req *http.Request, via []*http.Request) error {
+	if via[0].Method == "POST" {
+		return http.ErrUseLastResponse
+	}
+	if len(via) >= 10 {
+		return errors.New("stopped after 10 redirects")
 	}
+	return nil
 }
 
-func (c *Client) Do(method string, path string, values url.Values) (*http.Response, os.Error) {
-	url := "http://www.theninja-rpg.com" + path
+func (c *Client) Do(method string, path string, values url.Values) (*http.Response, error) {
+	url := c.BaseURL + path
 	req, err := http.NewRequest(method, url, bytes.NewBufferString(values.Encode()))
 	if err != nil {
 		return nil, err
@@ -47,6 +129,9 @@ func (c *Client) Do(method string, path string, values url.Values) (*http.Respon
 	if c.PSID != "" {
 		req.AddCookie(&http.Cookie{Name: "PHPSESSID", Value: c.PSID})
 	}
+	if c.Budget != nil {
+		c.Budget.Wait()
+	}
 	var tries int
 try:
 	resp, err := c.hc.Do(req)
@@ -65,40 +150,56 @@ try:
 	return resp, nil
 }
 
-func (c *Client) Get(path string) (*http.Response, os.Error) {
+func (c *Client) Get(path string) (*http.Response, error) {
 	return c.Do("GET", path, nil)
 }
 
-func (c *Client) Post(path string, values url.Values) (*http.Response, os.Error) {
+func (c *Client) Post(path string, values url.Values) (*http.Response, error) {
 	return c.Do("POST", path, values)
 }
 
-func (c *Client) ReadGet(path string) (*http.Response, string, os.Error) {
-	resp, err := c.Do("GET", path, nil)
-	if err != nil {
-		return nil, "", err
-	}
-	data, err := c.Read(resp)
-	return resp, data, err
+func (c *Client) ReadGet(path string) (*http.Response, string, error) {
+	return c.ReadDo("GET", path, nil)
+}
+
+func (c *Client) ReadPost(path string, values url.Values) (*http.Response, string, error) {
+	return c.ReadDo("POST", path, values)
 }
 
-func (c *Client) ReadPost(path string, values url.Values) (*http.Response, string, os.Error) {
-	resp, err := c.Do("POST", path, values)
+// ReadDo sends a request and reads the response. If the response shows the
+// session expired, it logs in again with Relogin and retries the request.
+func (c *Client) ReadDo(method string, path string, values url.Values) (*http.Response, string, error) {
+	resp, err := c.Do(method, path, values)
 	if err != nil {
 		return nil, "", err
 	}
 	data, err := c.Read(resp)
+	if err == ErrSessionExpired && c.Relogin != nil {
+		// Requests made while logging in again mustn't log in again.
+		relogin := c.Relogin
+		c.Relogin = nil
+		reloginErr := relogin()
+		c.Relogin = relogin
+		if reloginErr != nil {
+			return nil, "", reloginErr
+		}
+		resp, err = c.Do(method, path, values)
+		if err != nil {
+			return nil, "", err
+		}
+		data, err = c.Read(resp)
+	}
 	return resp, data, err
 }
 
-func (c *Client) Read(resp *http.Response) (string, os.Error) {
+func (c *Client) Read(resp *http.Response) (string, error) {
 	var tries int
 try:
-	data, err := ioutil.ReadAll(resp.Body)
+	data, err := io.ReadAll(resp.Body)
 	defer resp.Body.Close()
 	if err != nil {
 		if IsMaintenancePage(string(data)) {
-			time.Sleep(10e9)
+			time.Sleep(10 * time.Second)
 			goto try
 		}
 		tries++
@@ -107,10 +208,32 @@ try:
 		}
 		goto try
 	}
+	if c.RecordDir != "" {
+		// A page that can't be recorded is still a page the game answered.
+		if err := c.record(resp, data); err != nil {
+			log.Println("Can't record the page:", err)
+		}
+	}
+	if c.LoggedIn && IsLoggedOutPage(string(data)) {
+		c.LoggedIn = false
+		return "", ErrSessionExpired
+	}
 	return string(data), nil
 }
 
-func (c *Client) CaptchaURL(name, pass string) (string, os.Error) {
+var recordNameReplacer = strings.NewReplacer("/", "", "?", "", "&", " ")
+
+// record saves a downloaded page to RecordDir, naming it after the time,
+// method and path it was requested with.
+func (c *Client) record(resp *http.Response, data []byte) error {
+	name := fmt.Sprintf("%s %s %s.html",
+		time.Now().Format("2006-01-02 15.04.05.000"),
+		resp.Request.Method,
+		recordNameReplacer.Replace(resp.Request.URL.RequestURI()))
+	return os.WriteFile(filepath.Join(c.RecordDir, name), data, 0666)
+}
+
+func (c *Client) CaptchaURL(name, pass string) (string, error) {
 	_, data, err := c.ReadPost("/?id=1", url.Values{
 		"lgn_usr_stpd":   {name},
 		"login_password": {pass},
@@ -126,7 +249,7 @@ func (c *Client) CaptchaURL(name, pass string) (string, os.Error) {
 	return page.CaptchaURL, nil
 }
 
-func (c *Client) Login(proofCode, name, pass string) (bool, os.Error) {
+func (c *Client) Login(proofCode, name, pass string) (bool, error) {
 	resp, err := c.Post("/?id=1", url.Values{
 		"recaptcha_challenge_field": {proofCode},
 		"recaptcha_response_field":  {"manual_challenge"},
@@ -137,28 +260,75 @@ func (c *Client) Login(proofCode, name, pass string) (bool, os.Error) {
 	if err != nil {
 		return false, err
 	}
+	resp.Body.Close()
 	c.LoggedIn = resp.Header.Get("Location") == "?id=1"
+	if c.LoggedIn {
+		c.Account = name
+		c.LoginTime = time.Now()
+	}
 	return c.LoggedIn, nil
 }
 
-func (c *Client) detectEnterBattleLink(page BattleEntrancePage) (string, os.Error) {
+// Session returns what's needed to resume the login later.
+func (c *Client) Session() Session {
+	return Session{
+		Account:   c.Account,
+		PSID:      c.PSID,
+		LoginTime: c.LoginTime,
+	}
+}
+
+// Resume logs in with a saved session, if the game still accepts it.
+func (c *Client) Resume(s Session) error {
+	c.PSID = s.PSID
+	c.Account = s.Account
+	c.LoginTime = s.LoginTime
+	c.LoggedIn = true
+	if _, err := c.Sync(); err != nil {
+		c.PSID = ""
+		c.LoggedIn = false
+		return err
+	}
+	return nil
+}
+
+// Profile reads the character's profile, including its stats.
+func (c *Client) Profile() (p Profile, err error) {
+	if !c.LoggedIn {
+		err = ErrNotLoggedIn
+		return
+	}
+	_, data, err := c.ReadGet("/?id=2")
+	if err != nil {
+		return
+	}
+	page, err := ParseProfileWithStatsPage(data)
+	if err != nil {
+		return
+	}
+	c.Status = page.Status
+	c.syncStatus(page.Sidebar)
+	return Profile(page), nil
+}
+
+func (c *Client) detectEnterBattleLink(page BattleEntrancePage) (string, error) {
 	leftResp, err := c.Get(page.LeftImage)
 	if err != nil {
 		return "", err
 	}
+	leftResp.Body.Close()
 	rightResp, err := c.Get(page.RightImage)
 	if err != nil {
 		return "", err
 	}
-	leftSize := leftResp.Header.Get("Content-Length")
-	rightSize := rightResp.Header.Get("Content-Length")
-	if leftSize > rightSize {
+	rightResp.Body.Close()
+	if leftResp.ContentLength > rightResp.ContentLength {
 		return page.LeftLink, nil
 	}
 	return page.RightLink, nil
 }
 
-func (c *Client) EnterBattle() (opponentName string, err os.Error) {
+func (c *Client) EnterBattle() (opponentName string, err error) {
 	if err = c.require(statusAwake); err != nil {
 		return
 	}
@@ -170,6 +340,11 @@ func (c *Client) EnterBattle() (opponentName string, err os.Error) {
 	if err != nil {
 		return
 	}
+	if page.Hospitalized {
+		c.Status = statusHospitalized
+		err = ErrHospitalized
+		return
+	}
 	link, err := c.detectEnterBattleLink(page)
 	if err != nil {
 		return
@@ -187,7 +362,7 @@ func (c *Client) EnterBattle() (opponentName string, err os.Error) {
 	return
 }
 
-func (c *Client) Battleground() (bg Battleground, err os.Error) {
+func (c *Client) Battleground() (bg Battleground, err error) {
 	if err = c.require(statusBattle); err != nil {
 		return
 	}
@@ -203,7 +378,7 @@ func (c *Client) Battleground() (bg Battleground, err os.Error) {
 	return
 }
 
-func (c *Client) Attack(battleID int, actionID string, opponentID int) (round BattleRound, err os.Error) {
+func (c *Client) Attack(battleID int, actionID string, opponentID int) (round BattleRound, err error) {
 	if err = c.require(statusBattle); err != nil {
 		return
 	}
@@ -217,16 +392,22 @@ func (c *Client) Attack(battleID int, actionID string, opponentID int) (round Ba
 		return
 	}
 	if IsBattleSummaryPage(data) {
+		summary, err := ParseBattleSummaryPage(data)
 		c.Status = statusAwake
-		err = ErrBattleFinished
-		return
+		if summary.Hospitalized {
+			c.Status = statusHospitalized
+		}
+		if err != nil {
+			return round, err
+		}
+		return round, &BattleFinishedError{Summary: BattleSummary(summary)}
 	}
 	page, err := ParseBattlegroundPage(data)
 	if err != nil {
 		return
 	}
 	if !page.YourActionSubmitted {
-		err = os.NewError("No \"Your action has been submitted\" after attacking")
+		err = errors.New("No \"Your action has been submitted\" after attacking")
 		return
 	}
 	_, data, err = c.ReadGet("/?id=41")
@@ -237,23 +418,95 @@ func (c *Client) Attack(battleID int, actionID string, opponentID int) (round Ba
 	return BattleRound(roundPage), err
 }
 
-func (b *Battleground) Attack(c *Client, action, opponent string) (BattleRound, os.Error) {
-	actionID, ok := b.Actions[strings.ToLower(action)]
+func (b *Battleground) Attack(c *Client, action, opponent string) (BattleRound, error) {
+	actionID, ok := b.Actions[actionKey(action)]
 	if !ok {
-		return BattleRound{}, os.NewError("Action does not exist")
+		return BattleRound{}, errors.New("Action does not exist")
 	}
 	opponentID, ok := b.Opponents[strings.ToLower(opponent)]
 	if !ok {
-		return BattleRound{}, os.NewError("Opponent does not exist")
+		return BattleRound{}, errors.New("Opponent does not exist")
+	}
+	round, err := c.Attack(b.ID, actionID, opponentID)
+	if err == nil {
+		b.trackOpponentHealth(round)
+	}
+	return round, err
+}
+
+// EstimateMaxHealth sets the max health of an opponent whose health the
+// game didn't show, so its health is estimated from the damage it takes.
+func (b *Battleground) EstimateMaxHealth(opponent string, max float32) {
+	name := strings.ToLower(opponent)
+	h := b.OpponentHealth[name]
+	if h.MaxHealth > 0 || max <= 0 {
+		return
+	}
+	if b.OpponentHealth == nil {
+		b.OpponentHealth = make(map[string]OpponentHealth)
+	}
+	h.MaxHealth, h.Health, h.Estimated = max, max-h.Damage, true
+	if h.Health < 0 {
+		h.Health = 0
+	}
+	b.OpponentHealth[name] = h
+}
+
+// trackOpponentHealth updates the health of the opponents after a round,
+// to what the round shows or else by subtracting the damage they took.
+func (b *Battleground) trackOpponentHealth(round BattleRound) {
+	if b.OpponentHealth == nil {
+		b.OpponentHealth = make(map[string]OpponentHealth)
+	}
+	for name := range b.Opponents {
+		h := b.OpponentHealth[name]
+		for _, hit := range round.Hits {
+			if !strings.EqualFold(hit.To, name) {
+				continue
+			}
+			h.Damage += hit.Damage
+			if h.MaxHealth > 0 {
+				h.Health -= hit.Damage
+				h.Estimated = true
+			}
+		}
+		if shown, ok := round.OpponentHealth[name]; ok {
+			h.Health, h.MaxHealth, h.Estimated = shown.Health, shown.MaxHealth, false
+		}
+		if h.Health < 0 {
+			h.Health = 0
+		}
+		b.OpponentHealth[name] = h
+	}
+}
+
+// eatAllItem is the ramen shop's "eat all you can" item.
+const eatAllItem = 8
+
+func (c *Client) EatAll() (success bool, err error) {
+	return c.Buy(eatAllItem)
+}
+
+// Menu reads the ramen shop's menu.
+func (c *Client) Menu() (menu RamenMenu, err error) {
+	if err = c.require(statusAwake); err != nil {
+		return
+	}
+	_, data, err := c.ReadGet("/?id=25")
+	if err != nil {
+		return
 	}
-	return c.Attack(b.ID, actionID, opponentID)
+	page, err := ParseRamenMenuPage(data)
+	return RamenMenu(page), err
 }
 
-func (c *Client) EatAll() (success bool, err os.Error) {
+// Buy orders an item of the ramen shop's menu and eats it. It returns
+// false when the shop refuses to serve more food.
+func (c *Client) Buy(itemID int) (success bool, err error) {
 	if err = c.require(statusAwake); err != nil {
 		return
 	}
-	_, data, err := c.ReadGet("/?id=25&buy=8")
+	_, data, err := c.ReadGet(fmt.Sprintf("/?id=25&buy=%d", itemID))
 	if err != nil {
 		return
 	}
@@ -267,11 +520,14 @@ func (c *Client) EatAll() (success bool, err os.Error) {
 		success = true
 		return
 	}
-	err = os.NewError("Failed to eat: unexpected response after ordering \"eat all you can\"")
+	err = fmt.Errorf("Failed to eat: unexpected response after ordering item %d", itemID)
 	return
 }
 
-func (c *Client) Train(rank int, what string, offensive bool, amount int) (res TrainResult, err os.Error) {
+// Train trains a stat the given amount of times. An amount of -1 trains as
+// many times as chakra and stamina allow. Train fails with ErrTooTired
+// when they don't allow any.
+func (c *Client) Train(rank int, what string, offensive bool, amount int) (res TrainResult, err error) {
 	if err = c.require(statusAwake); err != nil {
 		return
 	}
@@ -284,7 +540,7 @@ func (c *Client) Train(rank int, what string, offensive bool, amount int) (res T
 	case rankChuunin:
 		pageId = 39
 	default:
-		err = os.NewError("Training is not supported for rank")
+		err = errors.New("Training is not supported for rank")
 		return
 	}
 	pageUrl := fmt.Sprintf("/?id=%d&page=train", pageId)
@@ -305,6 +561,9 @@ func (c *Client) Train(rank int, what string, offensive bool, amount int) (res T
 		if err != nil {
 			return res, err
 		}
+		if page.MaxAmount == 0 {
+			return res, ErrTooTired
+		}
 		amount = page.MaxAmount
 	}
 	_, data, err := c.ReadPost(pageUrl, url.Values{
@@ -316,29 +575,239 @@ func (c *Client) Train(rank int, what string, offensive bool, amount int) (res T
 	if err != nil {
 		return
 	}
+	if tooTiredToTrain(data) {
+		err = ErrTooTired
+		return
+	}
 	page, err := ParseTrainResultPage(data)
 	if err != nil {
 		return
 	}
+	page.Amount = amount
 	return TrainResult(page), nil
 }
 
-func (c *Client) require(status int) os.Error {
+// RunErrands runs the given amount of errands for money. An amount of -1
+// runs as many errands as the character's stamina allows.
+func (c *Client) RunErrands(amount int) (res ErrandsResult, err error) {
+	if err = c.require(statusAwake); err != nil {
+		return
+	}
+	if amount == -1 {
+		_, data, err := c.ReadPost("/?id=20", url.Values{
+			"Submit": {"Run errands"},
+		})
+		if err != nil {
+			return res, err
+		}
+		page, err := ParseErrandsAmountSelectionPage(data)
+		if err != nil {
+			return res, err
+		}
+		amount = page.MaxAmount
+	}
+	_, data, err := c.ReadPost("/?id=20", url.Values{
+		"errands_amount": {strconv.Itoa(amount)},
+		"Submit":         {"Run errands"},
+	})
+	if err != nil {
+		return
+	}
+	page, err := ParseErrandsResultPage(data)
+	if err != nil {
+		return
+	}
+	return ErrandsResult(page), nil
+}
+
+func (c *Client) require(status int) error {
 	if !c.LoggedIn {
 		return ErrNotLoggedIn
 	}
+	if status == statusAwake && c.Status == statusHospitalized {
+		return ErrHospitalized
+	}
 	if status == statusAwake && c.Status != statusAwake {
 		return ErrNotAwake
 	}
 	if status == statusBattle && c.Status != statusBattle {
 		return ErrNotInBattle
 	}
-	/*if status == statusAsleep && c.Status != statusAsleep {
+	if status == statusAsleep && c.Status != statusAsleep {
 		return ErrNotAsleep
-	}*/
+	}
+	if status == statusHospitalized && c.Status != statusHospitalized {
+		return ErrNotHospitalized
+	}
 	return nil
 }
 
-//func (c *Client) RunErrands(amount int) (res ErrandsResult, err os.Error)
-//func (c *Client) Sleep() (bool, err)
-//func (c *Client) Wake() (bool, err)
+// Sync corrects Status from the profile and its sidebar, for when
+// something unexpected happened, like an error in the middle of a battle.
+func (c *Client) Sync() (sidebar Sidebar, err error) {
+	if !c.LoggedIn {
+		err = ErrNotLoggedIn
+		return
+	}
+	_, data, err := c.ReadGet("/?id=2")
+	if err != nil {
+		return
+	}
+	sidebar, err = ParseSidebar(data)
+	if err != nil {
+		return
+	}
+	// Only the profile tells whether the character is asleep.
+	if status, err := parseStatus(parseFields(data)["status"]); err == nil {
+		c.Status = status
+	}
+	c.syncStatus(sidebar)
+	return
+}
+
+// syncStatus sets the status to what the sidebar of a page shows.
+func (c *Client) syncStatus(sidebar Sidebar) {
+	switch {
+	case sidebar.Hospitalized:
+		c.Status = statusHospitalized
+	case sidebar.InBattle:
+		c.Status = statusBattle
+	case c.Status != statusAsleep:
+		c.Status = statusAwake
+	}
+}
+
+// Hospital reads the hospital page, which tells whether the character is
+// hospitalized, how long until it is released and what healing costs.
+func (c *Client) Hospital() (h Hospital, err error) {
+	if !c.LoggedIn {
+		err = ErrNotLoggedIn
+		return
+	}
+	_, data, err := c.ReadGet("/?id=34")
+	if err != nil {
+		return
+	}
+	page, err := ParseHospitalPage(data)
+	if err != nil {
+		return
+	}
+	if page.Hospitalized {
+		c.Status = statusHospitalized
+	} else if c.Status == statusHospitalized {
+		c.Status = statusAwake
+	}
+	return Hospital(page), nil
+}
+
+// Heal pays the hospital to release the character right away.
+func (c *Client) Heal() (success bool, err error) {
+	if err = c.require(statusHospitalized); err != nil {
+		return
+	}
+	_, data, err := c.ReadGet("/?id=34&act=heal")
+	if err != nil {
+		return
+	}
+	page, err := ParseHospitalPage(data)
+	if err != nil {
+		return
+	}
+	if !page.Hospitalized {
+		c.Status = statusAwake
+		success = true
+	}
+	return
+}
+
+// Sleep puts the character to sleep, which regenerates health, chakra and
+// stamina faster but prevents fighting and training until Wake is called.
+func (c *Client) Sleep() (success bool, err error) {
+	if err = c.require(statusAwake); err != nil {
+		return
+	}
+	_, data, err := c.ReadGet("/?id=16&act=sleep")
+	if err != nil {
+		return
+	}
+	page, err := ParseSleepPage(data)
+	if err != nil {
+		return
+	}
+	if page.Asleep {
+		c.Status = statusAsleep
+		success = true
+	}
+	return
+}
+
+func (c *Client) Wake() (success bool, err error) {
+	if err = c.require(statusAsleep); err != nil {
+		return
+	}
+	_, data, err := c.ReadGet("/?id=16&act=wake")
+	if err != nil {
+		return
+	}
+	page, err := ParseSleepPage(data)
+	if err != nil {
+		return
+	}
+	if !page.Asleep {
+		c.Status = statusAwake
+		success = true
+	}
+	return
+}
+
+// Bank reads how much money is in pocket and how much in the bank.
+func (c *Client) Bank() (bank Bank, err error) {
+	if err = c.require(statusAwake); err != nil {
+		return
+	}
+	_, data, err := c.ReadGet("/?id=24")
+	if err != nil {
+		return
+	}
+	page, err := ParseBankPage(data)
+	return Bank(page), err
+}
+
+// Deposit moves amount ryo from the pocket to the bank.
+func (c *Client) Deposit(amount int) (Bank, error) {
+	return c.bankTransfer("deposit", "Deposit", amount)
+}
+
+// Withdraw moves amount ryo from the bank to the pocket.
+func (c *Client) Withdraw(amount int) (Bank, error) {
+	return c.bankTransfer("withdraw", "Withdraw", -amount)
+}
+
+// bankTransfer submits the bank form with the named button, and checks the
+// money in pocket changed by -change.
+func (c *Client) bankTransfer(button, label string, change int) (bank Bank, err error) {
+	before, err := c.Bank()
+	if err != nil {
+		return
+	}
+	amount := change
+	if amount < 0 {
+		amount = -amount
+	}
+	_, data, err := c.ReadPost("/?id=24", url.Values{
+		"amount": {strconv.Itoa(amount)},
+		button:   {label},
+	})
+	if err != nil {
+		return
+	}
+	page, err := ParseBankPage(data)
+	if err != nil {
+		return
+	}
+	bank = Bank(page)
+	if bank.Money != before.Money-change {
+		err = fmt.Errorf("Failed to %s %d ryo: %d ryo in pocket before and %d after", button, amount, before.Money, bank.Money)
+	}
+	return
+}
@@ -0,0 +1,498 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLogin(t *testing.T) {
+	s := newFakeServer(t)
+	c := s.client()
+
+	url, err := c.CaptchaURL(s.Name, s.Password)
+	if err != nil {
+		t.Fatal("CaptchaURL:", err)
+	}
+	if want := "http://www.google.com/recaptcha/api/noscript?k=6LcTestKey"; url != want {
+		t.Errorf("CaptchaURL = %q, want %q", url, want)
+	}
+	ok, err := c.Login("wrong", s.Name, s.Password)
+	if err != nil || ok {
+		t.Errorf("Login with a wrong proof code = %v, %v; want false", ok, err)
+	}
+	ok, err = c.Login(s.ProofCode, s.Name, s.Password)
+	if err != nil || !ok {
+		t.Fatalf("Login = %v, %v; want true", ok, err)
+	}
+	if !c.LoggedIn || c.PSID == "" {
+		t.Errorf("after Login, LoggedIn = %v and PSID = %q", c.LoggedIn, c.PSID)
+	}
+}
+
+func TestNotLoggedIn(t *testing.T) {
+	s := newFakeServer(t)
+	c := s.client()
+	if _, err := c.EnterBattle(); err != ErrNotLoggedIn {
+		t.Errorf("EnterBattle = %v, want ErrNotLoggedIn", err)
+	}
+	if _, err := c.EatAll(); err != ErrNotLoggedIn {
+		t.Errorf("EatAll = %v, want ErrNotLoggedIn", err)
+	}
+}
+
+func TestBattle(t *testing.T) {
+	s := newFakeServer(t)
+	c := s.loggedInClient()
+
+	opponent, err := c.EnterBattle()
+	if err != nil {
+		t.Fatal("EnterBattle:", err)
+	}
+	if opponent != "Wolf Cub" {
+		t.Errorf("opponent = %q, want Wolf Cub", opponent)
+	}
+	if c.Status != statusBattle {
+		t.Errorf("Status = %d after EnterBattle, want statusBattle", c.Status)
+	}
+	bg, err := c.Battleground()
+	if err != nil {
+		t.Fatal("Battleground:", err)
+	}
+	if bg.ID != 88231 {
+		t.Errorf("battle ID = %d, want 88231", bg.ID)
+	}
+
+	var rounds int
+	var finished *BattleFinishedError
+	for {
+		round, err := bg.Attack(c, "Clone Technique", opponent)
+		if errors.As(err, &finished) {
+			break
+		}
+		if err != nil {
+			t.Fatal("Attack:", err)
+		}
+		rounds++
+		if len(round.Hits) != 2 {
+			t.Errorf("round %d has %d hits, want 2", rounds, len(round.Hits))
+		}
+		if rounds > s.Rounds {
+			t.Fatal("battle did not finish")
+		}
+	}
+	if rounds != s.Rounds-1 {
+		t.Errorf("fought %d rounds before the summary, want %d", rounds, s.Rounds-1)
+	}
+	if c.Status != statusAwake {
+		t.Errorf("Status = %d after the battle, want statusAwake", c.Status)
+	}
+	if !errors.Is(finished, ErrBattleFinished) {
+		t.Error("BattleFinishedError doesn't match ErrBattleFinished")
+	}
+	if sum := finished.Summary; sum.Outcome != outcomeWon || sum.Exp != 45 || sum.Ryo != 120 || len(sum.Items) != 2 {
+		t.Errorf("Summary = %+v, want a win with 45 exp, 120 ryo and 2 items", sum)
+	}
+	if _, err := bg.Attack(c, "Rasengan", opponent); err == nil {
+		t.Error("Attack with an unknown action succeeded")
+	}
+}
+
+func TestSync(t *testing.T) {
+	s := newFakeServer(t)
+	c := s.loggedInClient()
+
+	if _, err := c.EnterBattle(); err != nil {
+		t.Fatal("EnterBattle:", err)
+	}
+	// Lose track of the battle, as after an error.
+	c.Status = statusAwake
+	sidebar, err := c.Sync()
+	if err != nil {
+		t.Fatal("Sync:", err)
+	}
+	if !sidebar.InBattle || c.Status != statusBattle {
+		t.Errorf("after Sync, InBattle = %v and Status = %d; want statusBattle", sidebar.InBattle, c.Status)
+	}
+	if _, err := c.Battleground(); err != nil {
+		t.Errorf("Battleground after Sync: %v", err)
+	}
+}
+
+func TestHospital(t *testing.T) {
+	s := newFakeServer(t)
+	s.LoseBattle = true
+	c := s.loggedInClient()
+
+	opponent, err := c.EnterBattle()
+	if err != nil {
+		t.Fatal("EnterBattle:", err)
+	}
+	bg, err := c.Battleground()
+	if err != nil {
+		t.Fatal("Battleground:", err)
+	}
+	for err == nil {
+		_, err = bg.Attack(c, "Wooden Staff", opponent)
+	}
+	if !errors.Is(err, ErrBattleFinished) {
+		t.Fatal("Attack:", err)
+	}
+	if c.Status != statusHospitalized {
+		t.Fatalf("Status = %d after losing, want statusHospitalized", c.Status)
+	}
+	if _, err := c.EnterBattle(); err != ErrHospitalized {
+		t.Errorf("EnterBattle while hospitalized = %v, want ErrHospitalized", err)
+	}
+
+	h, err := c.Hospital()
+	if err != nil {
+		t.Fatal("Hospital:", err)
+	}
+	if !h.Hospitalized || h.Price != fakeHealPrice || h.Timer != 270 {
+		t.Errorf("Hospital = %+v, want hospitalized for 270 seconds at %d ryo", h, fakeHealPrice)
+	}
+	ok, err := c.Heal()
+	if err != nil || !ok {
+		t.Fatalf("Heal = %v, %v; want true", ok, err)
+	}
+	if c.Status != statusAwake || s.Money != 1000-fakeHealPrice {
+		t.Errorf("after Heal, Status = %d and money = %d", c.Status, s.Money)
+	}
+	if _, err := c.Heal(); err != ErrNotHospitalized {
+		t.Errorf("Heal while healthy = %v, want ErrNotHospitalized", err)
+	}
+	if _, err := c.EnterBattle(); err != nil {
+		t.Errorf("EnterBattle after healing: %v", err)
+	}
+}
+
+func TestEatAll(t *testing.T) {
+	s := newFakeServer(t)
+	c := s.loggedInClient()
+
+	for i, want := range []bool{true, false} {
+		ate, err := c.EatAll()
+		if err != nil {
+			t.Fatal("EatAll:", err)
+		}
+		if ate != want {
+			t.Errorf("EatAll #%d = %v, want %v", i+1, ate, want)
+		}
+	}
+}
+
+func TestTrain(t *testing.T) {
+	s := newFakeServer(t)
+	c := s.loggedInClient()
+
+	res, err := c.Train(rankGenin, "nin", true, -1)
+	if err != nil {
+		t.Fatal("Train:", err)
+	}
+	if res.GainExp != 12 || res.GainStat != 0.35 {
+		t.Errorf("Train gained %d exp and %f stat, want 12 and 0.35", res.GainExp, res.GainStat)
+	}
+	if _, err := c.Train(rankGenin, "tai", false, 2); err != nil {
+		t.Fatal("Train:", err)
+	}
+	if len(s.trained) != 2 || s.trained[0] != s.MaxTrain || s.trained[1] != 2 {
+		t.Errorf("trained amounts %v, want [%d 2]", s.trained, s.MaxTrain)
+	}
+	if _, err := c.Train(rankJounin, "nin", true, -1); err == nil {
+		t.Error("Train as a jounin succeeded")
+	}
+
+	s.MaxTrain = 0
+	if _, err := c.Train(rankGenin, "nin", true, -1); err != ErrTooTired {
+		t.Errorf("Train without a train amount offered = %v, want ErrTooTired", err)
+	}
+	if _, err := c.Train(rankGenin, "nin", true, 2); err != ErrTooTired {
+		t.Errorf("Train 2 times when too tired = %v, want ErrTooTired", err)
+	}
+	if len(s.trained) != 2 {
+		t.Errorf("trained amounts %v after training too tired, want 2 amounts", s.trained)
+	}
+}
+
+func TestRunErrands(t *testing.T) {
+	s := newFakeServer(t)
+	c := s.loggedInClient()
+
+	res, err := c.RunErrands(-1)
+	if err != nil {
+		t.Fatal("RunErrands:", err)
+	}
+	if res.MoneyEarned != 1120 || res.SpentStamina != 48 {
+		t.Errorf("RunErrands earned %d ryo for %f stamina, want 1120 for 48", res.MoneyEarned, res.SpentStamina)
+	}
+	if len(s.errands) != 1 || s.errands[0] != s.MaxErrands {
+		t.Errorf("errands amounts %v, want [%d]", s.errands, s.MaxErrands)
+	}
+}
+
+func TestProfile(t *testing.T) {
+	s := newFakeServer(t)
+	c := s.loggedInClient()
+
+	p, err := c.Profile()
+	if err != nil {
+		t.Fatal("Profile:", err)
+	}
+	if p.Name != "zippo" || p.Rank != "Genin" || p.Level != 12 {
+		t.Errorf("Profile = %s, %s, level %d; want zippo, Genin, level 12", p.Name, p.Rank, p.Level)
+	}
+}
+
+func TestRecord(t *testing.T) {
+	s := newFakeServer(t)
+	c := s.loggedInClient()
+
+	c.RecordDir = t.TempDir()
+	if _, err := c.Profile(); err != nil {
+		t.Fatal("Profile:", err)
+	}
+	if files, _ := os.ReadDir(c.RecordDir); len(files) != 1 {
+		t.Errorf("recorded %d pages, want 1", len(files))
+	}
+
+	// Failing to record doesn't fail the request.
+	c.RecordDir = filepath.Join(t.TempDir(), "missing")
+	if _, err := c.Profile(); err != nil {
+		t.Errorf("Profile without a record directory: %v", err)
+	}
+}
+
+func TestSleepWake(t *testing.T) {
+	s := newFakeServer(t)
+	c := s.loggedInClient()
+
+	if _, err := c.Wake(); err != ErrNotAsleep {
+		t.Errorf("Wake while awake = %v, want ErrNotAsleep", err)
+	}
+	ok, err := c.Sleep()
+	if err != nil || !ok {
+		t.Fatalf("Sleep = %v, %v; want true", ok, err)
+	}
+	if c.Status != statusAsleep || !s.asleep {
+		t.Errorf("after Sleep, Status = %d and server asleep = %v", c.Status, s.asleep)
+	}
+	if _, err := c.EnterBattle(); err != ErrNotAwake {
+		t.Errorf("EnterBattle while asleep = %v, want ErrNotAwake", err)
+	}
+	ok, err = c.Wake()
+	if err != nil || !ok {
+		t.Fatalf("Wake = %v, %v; want true", ok, err)
+	}
+	if c.Status != statusAwake || s.asleep {
+		t.Errorf("after Wake, Status = %d and server asleep = %v", c.Status, s.asleep)
+	}
+}
+
+func TestResume(t *testing.T) {
+	s := newFakeServer(t)
+	session := s.loggedInClient().Session()
+	if session.Account != s.Name || session.PSID == "" || session.LoginTime.IsZero() {
+		t.Fatalf("Session = %+v, want the account, PHPSESSID and login time", session)
+	}
+
+	c := s.client()
+	if err := c.Resume(session); err != nil {
+		t.Fatal("Resume:", err)
+	}
+	if !c.LoggedIn || c.PSID != session.PSID {
+		t.Errorf("after Resume, LoggedIn = %v and PSID = %q", c.LoggedIn, c.PSID)
+	}
+	if _, err := c.EatAll(); err != nil {
+		t.Errorf("EatAll after Resume: %v", err)
+	}
+
+	c = s.client()
+	session.PSID = "expired"
+	if err := c.Resume(session); err == nil {
+		t.Error("Resume of an expired session succeeded")
+	}
+	if c.LoggedIn {
+		t.Error("LoggedIn after failing to resume")
+	}
+}
+
+func TestResumeAsleep(t *testing.T) {
+	s := newFakeServer(t)
+	session := s.loggedInClient().Session()
+	s.asleep = true
+
+	c := s.client()
+	if err := c.Resume(session); err != nil {
+		t.Fatal("Resume:", err)
+	}
+	if c.Status != statusAsleep {
+		t.Fatalf("after resuming an asleep character, Status = %d, want asleep", c.Status)
+	}
+	if ok, err := c.Wake(); err != nil || !ok {
+		t.Fatalf("Wake = %v, %v; want true", ok, err)
+	}
+	if _, err := c.Profile(); err != nil || c.Status != statusAwake {
+		t.Errorf("after Profile, Status = %d (%v), want awake", c.Status, err)
+	}
+
+	s.asleep = true
+	if _, err := c.Profile(); err != nil || c.Status != statusAsleep {
+		t.Errorf("Profile of an asleep character left Status = %d (%v), want asleep", c.Status, err)
+	}
+}
+
+func TestSessionExpired(t *testing.T) {
+	s := newFakeServer(t)
+	c := s.loggedInClient()
+
+	s.expireSessions()
+	if _, err := c.EatAll(); err != ErrSessionExpired {
+		t.Fatalf("EatAll after the session expired = %v, want ErrSessionExpired", err)
+	}
+	if c.LoggedIn {
+		t.Error("LoggedIn after the session expired")
+	}
+
+	var relogins int
+	c = s.loggedInClient()
+	c.Relogin = func() error {
+		relogins++
+		_, err := c.Login(s.ProofCode, s.Name, s.Password)
+		return err
+	}
+	s.expireSessions()
+	ate, err := c.EatAll()
+	if err != nil || !ate {
+		t.Fatalf("EatAll with Relogin = %v, %v; want true", ate, err)
+	}
+	if relogins != 1 || !c.LoggedIn {
+		t.Errorf("logged in again %d times, LoggedIn = %v; want once", relogins, c.LoggedIn)
+	}
+}
+
+func TestTrackOpponentHealth(t *testing.T) {
+	bg := &Battleground{Opponents: map[string]int{"wolf cub": 5512}}
+	hit := func(damage float32) BattleRound {
+		var round BattleRound
+		round.Hits = []BattleHit{{Damage: damage, By: "zippo", To: "Wolf Cub"}, {Damage: 3, By: "Wolf Cub", To: "zippo"}}
+		return round
+	}
+
+	// Until the health is shown, only the damage is known.
+	bg.trackOpponentHealth(hit(10))
+	if h := bg.OpponentHealth["wolf cub"]; h != (OpponentHealth{Damage: 10}) {
+		t.Errorf("before the health is shown, OpponentHealth = %+v", h)
+	}
+	round := hit(20)
+	round.OpponentHealth = map[string]OpponentHealth{"wolf cub": {Health: 50, MaxHealth: 80}}
+	bg.trackOpponentHealth(round)
+	if h := bg.OpponentHealth["wolf cub"]; h != (OpponentHealth{Health: 50, MaxHealth: 80, Damage: 30}) {
+		t.Errorf("when the health is shown, OpponentHealth = %+v", h)
+	}
+	bg.trackOpponentHealth(hit(15.5))
+	if h := bg.OpponentHealth["wolf cub"]; h != (OpponentHealth{Health: 34.5, MaxHealth: 80, Damage: 45.5, Estimated: true}) {
+		t.Errorf("when the health is estimated, OpponentHealth = %+v", h)
+	}
+	bg.trackOpponentHealth(hit(40))
+	if h := bg.OpponentHealth["wolf cub"]; h.Health != 0 {
+		t.Errorf("after more damage than health, Health = %f", h.Health)
+	}
+
+	// A max health estimated elsewhere starts the estimates when the
+	// health is never shown, and doesn't override a shown one.
+	bg = &Battleground{Opponents: map[string]int{"wolf cub": 5512}}
+	bg.trackOpponentHealth(hit(10))
+	bg.EstimateMaxHealth("Wolf Cub", 60)
+	bg.trackOpponentHealth(hit(20))
+	if h := bg.OpponentHealth["wolf cub"]; h != (OpponentHealth{Health: 30, MaxHealth: 60, Damage: 30, Estimated: true}) {
+		t.Errorf("with an estimated max health, OpponentHealth = %+v", h)
+	}
+	bg.OpponentHealth["wolf cub"] = OpponentHealth{Health: 50, MaxHealth: 80}
+	bg.EstimateMaxHealth("Wolf Cub", 60)
+	if h := bg.OpponentHealth["wolf cub"]; h.MaxHealth != 80 {
+		t.Errorf("EstimateMaxHealth overrode a shown max health of 80 with %f", h.MaxHealth)
+	}
+}
+
+func TestBudget(t *testing.T) {
+	budget := &Budget{Interval: 20 * time.Millisecond}
+	s := newFakeServer(t)
+	a, b := s.client(), s.client()
+	a.Budget, b.Budget = budget, budget
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		for _, c := range []*Client{a, b} {
+			if _, err := c.Get("/"); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	// Six requests take at least five intervals.
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Errorf("6 requests sharing a budget took %s, want at least 100ms", elapsed)
+	}
+}
+
+func TestBank(t *testing.T) {
+	s := newFakeServer(t)
+	s.Money, s.Banked = 1000, 50
+	c := s.loggedInClient()
+
+	bank, err := c.Bank()
+	if err != nil {
+		t.Fatal("Bank:", err)
+	}
+	if bank.Money != 1000 || bank.BankedMoney != 50 {
+		t.Errorf("Bank = %d in pocket and %d banked, want 1000 and 50", bank.Money, bank.BankedMoney)
+	}
+	bank, err = c.Deposit(800)
+	if err != nil {
+		t.Fatal("Deposit:", err)
+	}
+	if bank.Money != 200 || bank.BankedMoney != 850 || s.Banked != 850 {
+		t.Errorf("after depositing, %d in pocket and %d banked, want 200 and 850", bank.Money, bank.BankedMoney)
+	}
+	bank, err = c.Withdraw(100)
+	if err != nil {
+		t.Fatal("Withdraw:", err)
+	}
+	if bank.Money != 300 || bank.BankedMoney != 750 {
+		t.Errorf("after withdrawing, %d in pocket and %d banked, want 300 and 750", bank.Money, bank.BankedMoney)
+	}
+	if _, err := c.Deposit(5000); err == nil {
+		t.Error("Deposit of more than in pocket succeeded")
+	}
+}
+
+func TestMenuBuy(t *testing.T) {
+	s := newFakeServer(t)
+	s.Meals = 2
+	c := s.loggedInClient()
+
+	menu, err := c.Menu()
+	if err != nil {
+		t.Fatal("Menu:", err)
+	}
+	if len(menu.Items) != 4 || menu.Items[1].Name != "Miso Ramen" || menu.Items[1].Price != 22 {
+		t.Errorf("Menu = %+v", menu.Items)
+	}
+	for i, want := range []bool{true, true, false} {
+		ate, err := c.Buy(menu.Items[1].ID)
+		if err != nil {
+			t.Fatal("Buy:", err)
+		}
+		if ate != want {
+			t.Errorf("Buy #%d = %v, want %v", i+1, ate, want)
+		}
+	}
+	if got := strings.Join(s.bought, ","); got != "2,2,2" {
+		t.Errorf("items ordered = %s, want 2,2,2", got)
+	}
+	if _, err := c.Buy(99); err == nil {
+		t.Error("Buy of an item not on the menu succeeded")
+	}
+}
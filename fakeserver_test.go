@@ -0,0 +1,385 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeServer imitates the game closely enough for the Client to log in,
+// sleep, battle, heal, eat, train and run errands against it. Pages are served
+// from testdata, and the fields below script how the game behaves.
+type fakeServer struct {
+	*httptest.Server
+	t *testing.T
+
+	Name, Password, ProofCode string
+	Rounds                    int  // rounds a battle lasts
+	Meals                     int  // meals the ramen shop serves before refusing
+	MaxTrain                  int  // highest train amount offered, none when 0
+	MaxErrands                int  // highest errands amount offered
+	LoseBattle                bool // whether battles end in the hospital
+	OpponentHealth            bool // whether battle rounds show the opponent's health
+	BrokenProfile             bool // whether the profile is a page the parsers don't understand
+	Money                     int  // ryo in pocket, spent on healing
+	Banked                    int  // ryo in the bank
+
+	mu        sync.Mutex
+	sessions  map[string]bool // PHPSESSID to whether it is logged in
+	nsessions int
+	inBattle  bool
+	asleep    bool
+	hospital  bool
+	submitted bool
+	round     int
+	trained   []int    // train amounts that were submitted
+	errands   []int    // errands amounts that were submitted
+	bought    []string // ramen items that were ordered
+}
+
+// The antibot images of battleentrance.html. The bigger one marks the
+// link that enters the battle.
+const (
+	fakeEnterImage = "/images/antibot/9b1e.gif"
+	fakeEnterAct   = "a8f3c1"
+)
+
+func newFakeServer(t *testing.T) *fakeServer {
+	s := &fakeServer{
+		t:          t,
+		Name:       "zippo",
+		Password:   "secret",
+		ProofCode:  "03AHJ_proof",
+		Rounds:     3,
+		Meals:      1,
+		MaxTrain:   6,
+		MaxErrands: 4,
+		Money:      1000,
+		sessions:   make(map[string]bool),
+	}
+	s.Server = httptest.NewServer(s)
+	t.Cleanup(s.Close)
+	return s
+}
+
+// client returns a Client sending its requests to the server.
+func (s *fakeServer) client() *Client {
+	c := NewClient()
+	c.BaseURL = s.URL
+	return c
+}
+
+// loggedInClient returns a Client that went through the captcha login.
+func (s *fakeServer) loggedInClient() *Client {
+	c := s.client()
+	if _, err := c.CaptchaURL(s.Name, s.Password); err != nil {
+		s.t.Fatal("CaptchaURL:", err)
+	}
+	ok, err := c.Login(s.ProofCode, s.Name, s.Password)
+	if err != nil || !ok {
+		s.t.Fatalf("Login = %v, %v", ok, err)
+	}
+	return c
+}
+
+// expireSessions logs everyone out, as the game does after a while.
+func (s *fakeServer) expireSessions() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for psid := range s.sessions {
+		s.sessions[psid] = false
+	}
+}
+
+func (s *fakeServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if strings.HasPrefix(r.URL.Path, "/images/antibot/") {
+		size := 300
+		if r.URL.Path == fakeEnterImage {
+			size = 900
+		}
+		w.Header().Set("Content-Type", "image/gif")
+		w.Write(make([]byte, size))
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		s.t.Error("fake server:", err)
+	}
+	query := r.URL.Query()
+	if query.Get("id") == "1" {
+		s.login(w, r)
+		return
+	}
+	cookie, err := r.Cookie("PHPSESSID")
+	if err != nil || !s.sessions[cookie.Value] {
+		s.serve(w, "sidebar-loggedout.html")
+		return
+	}
+	switch query.Get("id") {
+	case "2":
+		if s.BrokenProfile {
+			s.serve(w, "sidebar-awake.html")
+			return
+		}
+		if s.asleep {
+			s.serveWith(w, "profile.html", strings.NewReplacer("<td>Awake</td>", "<td>Asleep</td>"))
+			return
+		}
+		s.serve(w, "profile.html")
+	case "16":
+		s.home(w, r)
+	case "20":
+		s.runErrands(w, r)
+	case "24":
+		s.bank(w, r)
+	case "25":
+		s.ramen(w, r)
+	case "34":
+		s.hospitalPage(w, r)
+	case "35":
+		s.battleEntrance(w, r)
+	case "41":
+		s.battleground(w, r)
+	case "18", "29", "39":
+		s.train(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// Sidebar rows the fake server adds to the pages it serves.
+const (
+	fakeSidebarTimer = `<tr><td><b>Logout timer:</b>`
+	fakeInBattle     = `<tr><td><a href="?id=41">In battle!</a></td></tr>`
+	fakeHospitalized = `<tr><td><a href="?id=34">Hospitalized!</a></td></tr>`
+)
+
+func (s *fakeServer) serve(w http.ResponseWriter, name string) {
+	s.serveWith(w, name, nil)
+}
+
+// serveWith serves a page after filling its placeholders with r.
+func (s *fakeServer) serveWith(w http.ResponseWriter, name string, r *strings.Replacer) {
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		s.t.Error("fake server:", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	page := string(data)
+	if r != nil {
+		page = r.Replace(page)
+	}
+	// Like the game, show the character's state in the sidebar.
+	if s.inBattle && !strings.Contains(page, fakeInBattle) {
+		page = strings.Replace(page, fakeSidebarTimer, fakeInBattle+fakeSidebarTimer, 1)
+	}
+	if s.hospital && !strings.Contains(page, fakeHospitalized) {
+		page = strings.Replace(page, fakeSidebarTimer, fakeHospitalized+fakeSidebarTimer, 1)
+	}
+	w.Header().Set("Content-Type", "text/html")
+	w.Write([]byte(page))
+}
+
+func (s *fakeServer) login(w http.ResponseWriter, r *http.Request) {
+	psid := ""
+	if cookie, err := r.Cookie("PHPSESSID"); err == nil {
+		psid = cookie.Value
+	} else {
+		s.nsessions++
+		psid = fmt.Sprintf("fakesession%d", s.nsessions)
+		http.SetCookie(w, &http.Cookie{Name: "PHPSESSID", Value: psid})
+	}
+	if r.PostForm.Get("lgn_usr_stpd") != s.Name || r.PostForm.Get("login_password") != s.Password {
+		s.serve(w, "sidebar-loggedout.html")
+		return
+	}
+	if r.PostForm.Get("recaptcha_challenge_field") != s.ProofCode {
+		s.serve(w, "login-captcha.html")
+		return
+	}
+	s.sessions[psid] = true
+	w.Header().Set("Location", "?id=1")
+	w.WriteHeader(http.StatusFound)
+}
+
+func (s *fakeServer) home(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Query().Get("act") {
+	case "sleep":
+		s.asleep = true
+	case "wake":
+		s.asleep = false
+	}
+	if s.asleep {
+		s.serve(w, "sleep-asleep.html")
+		return
+	}
+	s.serve(w, "sleep-awake.html")
+}
+
+func (s *fakeServer) ramen(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Query().Get("buy") {
+	case "":
+		s.serve(w, "ramen.html")
+		return
+	case "1", "2", "3", "8":
+		s.bought = append(s.bought, r.URL.Query().Get("buy"))
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	if s.Meals == 0 {
+		s.serve(w, "server/ramen-full.html")
+		return
+	}
+	s.Meals--
+	s.serve(w, "server/ramen-ate.html")
+}
+
+func (s *fakeServer) bank(w http.ResponseWriter, r *http.Request) {
+	if r.Method == "POST" {
+		amount, err := strconv.Atoi(r.PostForm.Get("amount"))
+		switch {
+		case err != nil || amount <= 0:
+		case r.PostForm.Get("deposit") != "" && amount <= s.Money:
+			s.Money -= amount
+			s.Banked += amount
+		case r.PostForm.Get("withdraw") != "" && amount <= s.Banked:
+			s.Banked -= amount
+			s.Money += amount
+		}
+	}
+	s.serveWith(w, "server/bank.html", strings.NewReplacer(
+		"{{money}}", strconv.Itoa(s.Money),
+		"{{bank}}", strconv.Itoa(s.Banked),
+	))
+}
+
+// fakeHealPrice is what healing costs in hospital.html.
+const fakeHealPrice = 350
+
+func (s *fakeServer) hospitalPage(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Query().Get("act") == "heal" && s.hospital && s.Money >= fakeHealPrice {
+		s.Money -= fakeHealPrice
+		s.hospital = false
+	}
+	if s.hospital {
+		s.serve(w, "hospital.html")
+		return
+	}
+	s.serve(w, "hospital-released.html")
+}
+
+func (s *fakeServer) battleEntrance(w http.ResponseWriter, r *http.Request) {
+	if s.hospital {
+		s.serve(w, "hospital.html")
+		return
+	}
+	switch r.URL.Query().Get("act") {
+	case "":
+		s.serve(w, "battleentrance.html")
+	case fakeEnterAct:
+		s.inBattle, s.submitted, s.round = true, false, 0
+		s.serve(w, "battleprepare.html")
+	default:
+		// The wrong kunai was clicked.
+		s.serve(w, "sidebar-awake.html")
+	}
+}
+
+func (s *fakeServer) battleground(w http.ResponseWriter, r *http.Request) {
+	if !s.inBattle {
+		s.serve(w, "sidebar-awake.html")
+		return
+	}
+	if r.URL.Query().Get("act") != "do" {
+		if s.submitted {
+			s.submitted = false
+			if s.OpponentHealth {
+				s.serve(w, "battleround-opponenthealth.html")
+				return
+			}
+			s.serve(w, "battleround.html")
+			return
+		}
+		s.serve(w, "battleground.html")
+		return
+	}
+	if r.Method != "POST" {
+		s.t.Errorf("fake server: battle action sent with %s", r.Method)
+	}
+	if got := r.PostForm.Get("battle_id"); got != "88231" {
+		s.t.Errorf("fake server: battle_id = %q, want 88231", got)
+	}
+	if got := r.PostForm.Get("opponent"); got != "5512" {
+		s.t.Errorf("fake server: opponent = %q, want 5512", got)
+	}
+	switch action := r.PostForm.Get("action"); action {
+	case "J12", "W3", "A1":
+	default:
+		s.t.Errorf("fake server: unknown action %q", action)
+	}
+	s.round++
+	if s.round >= s.Rounds {
+		s.inBattle = false
+		if s.LoseBattle {
+			s.hospital = true
+			s.serve(w, "battlesummary-lost.html")
+			return
+		}
+		s.serve(w, "battlesummary.html")
+		return
+	}
+	s.submitted = true
+	s.serve(w, "battleground-submitted.html")
+}
+
+func (s *fakeServer) train(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Query().Get("page") != "train" || r.Method != "POST" {
+		http.NotFound(w, r)
+		return
+	}
+	if r.PostForm.Get("train_amount") == "" {
+		if s.MaxTrain == 0 {
+			s.serve(w, "trainamount-tired.html")
+			return
+		}
+		s.serve(w, "trainamount.html")
+		return
+	}
+	amount, err := strconv.Atoi(r.PostForm.Get("train_amount"))
+	if s.MaxTrain == 0 {
+		s.serve(w, "trainresult-tired.html")
+		return
+	}
+	if err != nil || amount < 1 || amount > s.MaxTrain {
+		s.t.Errorf("fake server: invalid train amount %q", r.PostForm.Get("train_amount"))
+	}
+	s.trained = append(s.trained, amount)
+	s.serve(w, "trainresult.html")
+}
+
+func (s *fakeServer) runErrands(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.NotFound(w, r)
+		return
+	}
+	if r.PostForm.Get("errands_amount") == "" {
+		s.serve(w, "errandsamount.html")
+		return
+	}
+	amount, err := strconv.Atoi(r.PostForm.Get("errands_amount"))
+	if err != nil || amount < 1 || amount > s.MaxErrands {
+		s.t.Errorf("fake server: invalid errands amount %q", r.PostForm.Get("errands_amount"))
+	}
+	s.errands = append(s.errands, amount)
+	s.serve(w, "errandsresult.html")
+}
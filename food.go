@@ -0,0 +1,147 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// cheapestMeal returns the items, by index in the menu, that together
+// restore at least deficit health for the least money, and what they
+// cost. It returns no items when the menu can't restore the deficit.
+func cheapestMeal(menu []MenuItem, deficit float32) (items []int, cost int) {
+	need := int(math.Ceil(float64(deficit)))
+	if need <= 0 {
+		return nil, 0
+	}
+	// costs[h] is the least money restoring at least h health, and last[h]
+	// the last item bought for it.
+	costs := make([]int, need+1)
+	last := make([]int, need+1)
+	for h := 1; h <= need; h++ {
+		costs[h], last[h] = -1, -1
+		for i, item := range menu {
+			rest := 0
+			switch {
+			case item.Full:
+			case item.Restore > 0:
+				rest = h - int(item.Restore)
+				if rest < 0 {
+					rest = 0
+				}
+			default:
+				continue
+			}
+			if costs[rest] == -1 {
+				continue
+			}
+			if c := costs[rest] + item.Price; costs[h] == -1 || c < costs[h] {
+				costs[h], last[h] = c, i
+			}
+		}
+	}
+	if costs[need] == -1 {
+		return nil, 0
+	}
+	for h := need; h > 0; {
+		item := menu[last[h]]
+		items = append(items, last[h])
+		if item.Full {
+			break
+		}
+		h -= int(item.Restore)
+	}
+	return items, costs[need]
+}
+
+// eat restores health at the ramen shop as the food policy says, and saves
+// what the meal cost, read as the difference in money on the profile, to
+// the history. When the profile can't be read, the meal is saved with an
+// unknown cost. It returns whether the shop served a meal.
+func (b *bot) eat() (ate bool, err error) {
+	before, perr := b.profile()
+	if perr != nil {
+		// The cheapest meal depends on the missing health.
+		if b.cnf.FoodPolicy == "cheapest" {
+			return false, fmt.Errorf("Can't read profile: %w", perr)
+		}
+		b.log.Println("Can't read profile, the meal's cost will be unknown:", perr)
+	}
+	if b.cnf.FoodPolicy == "cheapest" {
+		ate, err = b.eatCheapest(before)
+	} else {
+		ate, err = b.c.EatAll()
+		if err == nil && ate {
+			b.log.Println("Ate all you can")
+		} else if err == nil {
+			b.log.Println("Can't eat anymore")
+		}
+	}
+	if err != nil {
+		return false, fmt.Errorf("Failed to eat: %w", err)
+	}
+	if !ate {
+		return
+	}
+	meal := MealRecord{Time: time.Now(), Spent: spentUnknown}
+	if perr == nil {
+		if after, err := b.profile(); err == nil {
+			meal.Spent = before.Money - after.Money
+		} else {
+			b.log.Println("Can't read profile, the meal's cost is unknown:", err)
+		}
+	}
+	if err := b.history.AddMeal(meal); err != nil {
+		b.log.Println("Can't save the meal to the history:", err)
+	}
+	return
+}
+
+// eatCheapest buys the cheapest combination of the menu that restores the
+// health missing on the profile, unless it would overspend the daily budget.
+func (b *bot) eatCheapest(p Profile) (ate bool, err error) {
+	menu, err := b.c.Menu()
+	if err != nil {
+		return
+	}
+	items, cost := cheapestMeal(menu.Items, p.MaxHealth-p.Health)
+	if len(items) == 0 {
+		b.log.Println("Nothing to eat")
+		return
+	}
+	if b.cnf.FoodBudget > 0 {
+		now := time.Now()
+		meals, err := b.history.Meals(time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location()), time.Time{})
+		if err != nil {
+			return false, fmt.Errorf("Can't read meals: %w", err)
+		}
+		var spent int
+		for _, m := range meals {
+			if m.Spent != spentUnknown {
+				spent += m.Spent
+			}
+		}
+		if spent+cost > b.cnf.FoodBudget {
+			b.log.Printf("A meal of %d ryo would overspend today's food budget, %d of %d ryo are spent\n", cost, spent, b.cnf.FoodBudget)
+			return false, nil
+		}
+	}
+	var names []string
+	for _, i := range items {
+		served, err := b.c.Buy(menu.Items[i].ID)
+		if err != nil {
+			return ate, err
+		}
+		if !served {
+			b.log.Println("Can't eat anymore")
+			break
+		}
+		ate = true
+		names = append(names, menu.Items[i].Name)
+	}
+	if ate {
+		b.log.Printf("Ate %s\n", strings.Join(names, ", "))
+	}
+	return
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"io"
+	"log"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCheapestMeal(t *testing.T) {
+	menu := []MenuItem{
+		{ID: 1, Name: "Small Bowl", Restore: 25, Price: 10},
+		{ID: 2, Name: "Miso Ramen", Restore: 60, Price: 22},
+		{ID: 3, Name: "Large Bowl", Restore: 150, Price: 50},
+		{ID: 8, Name: "Eat all you can", Full: true, Price: 120},
+	}
+	tests := []struct {
+		deficit float32
+		items   []int
+		cost    int
+	}{
+		{0, nil, 0},
+		{20, []int{0}, 10},
+		{25.5, []int{0, 0}, 20}, // two small bowls are cheaper than a miso ramen
+		{80, []int{0, 1}, 32},   // 85 health
+		{150, []int{2}, 50},     // one large bowl
+		{600, []int{3}, 120},    // eating all you can beats four large bowls
+		{160, []int{0, 2}, 60},  // 175 health
+	}
+	for _, test := range tests {
+		items, cost := cheapestMeal(menu, test.deficit)
+		if !reflect.DeepEqual(items, test.items) || cost != test.cost {
+			t.Errorf("cheapestMeal for %v health = %v for %d ryo, want %v for %d ryo", test.deficit, items, cost, test.items, test.cost)
+		}
+	}
+	if items, _ := cheapestMeal(menu[:1:1], 100); len(items) != 4 {
+		t.Errorf("cheapestMeal of small bowls for 100 health = %v, want 4 bowls", items)
+	}
+	if items, _ := cheapestMeal(nil, 100); items != nil {
+		t.Errorf("cheapestMeal of an empty menu = %v", items)
+	}
+}
+
+func TestEatUnknownCost(t *testing.T) {
+	s := newFakeServer(t)
+	s.Meals = 2
+	b := newBot(s.loggedInClient(), config{Name: s.Name, FoodPolicy: "all"}, log.New(io.Discard, "", 0), modeBattle)
+	b.history = &History{Dir: t.TempDir()}
+
+	s.BrokenProfile = true
+	if ate, err := b.eat(); err != nil || !ate {
+		t.Fatalf("eat without a profile = %v, %v; want true", ate, err)
+	}
+	s.BrokenProfile = false
+	if ate, err := b.eat(); err != nil || !ate {
+		t.Fatalf("eat = %v, %v; want true", ate, err)
+	}
+	meals, err := b.history.Meals(time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatal("Meals:", err)
+	}
+	if len(meals) != 2 || meals[0].Spent != spentUnknown || meals[1].Spent != 0 {
+		t.Errorf("meals = %+v, want one of unknown cost, then one of 0 ryo", meals)
+	}
+}
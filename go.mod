@@ -0,0 +1,5 @@
+module github.com/zippoxer/ninbot
+
+go 1.21
+
+require gopkg.in/ini.v1 v1.67.0
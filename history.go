@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// BattleRecord is a battle as kept in the history.
+type BattleRecord struct {
+	Start, End time.Time
+	BattleID   int
+	Opponent   string
+	Rounds     []RoundRecord
+	Summary    BattleSummary
+}
+
+// RoundRecord is the action chosen in a round of a battle and its hits.
+type RoundRecord struct {
+	Action string
+	Hits   []BattleHit
+}
+
+// TrainRecord is a training session as kept in the history. Stat is
+// written as in the stat sequence of the configuration, such as "+nin".
+type TrainRecord struct {
+	Time   time.Time
+	Stat   string
+	Result TrainResult
+}
+
+// MealRecord is a visit to the ramen shop as kept in the history.
+type MealRecord struct {
+	Time  time.Time
+	Spent int // ryo paid for the meal, or spentUnknown
+}
+
+// spentUnknown is the Spent of a meal whose cost couldn't be read.
+const spentUnknown = -1
+
+// History keeps what an account did in JSON-lines files under Dir, one
+// record per line, so it can be analysed across many runs.
+type History struct {
+	Dir string
+}
+
+// historyDir returns the directory the history of an account is kept in.
+func historyDir(account string) string {
+	return filepath.Join("history", account)
+}
+
+// AddBattle appends a finished battle to the history.
+func (h *History) AddBattle(r BattleRecord) error {
+	return h.append("battles.jsonl", r)
+}
+
+// Battles returns the battles that started in [from, to). A zero time
+// leaves that end of the range open.
+func (h *History) Battles(from, to time.Time) ([]BattleRecord, error) {
+	var battles []BattleRecord
+	err := h.read("battles.jsonl", func(dec func(v interface{}) error) error {
+		var r BattleRecord
+		if err := dec(&r); err != nil {
+			return err
+		}
+		if inRange(r.Start, from, to) {
+			battles = append(battles, r)
+		}
+		return nil
+	})
+	return battles, err
+}
+
+// AddTraining appends a training session to the history.
+func (h *History) AddTraining(r TrainRecord) error {
+	return h.append("trainings.jsonl", r)
+}
+
+// Trainings returns the training sessions in [from, to).
+func (h *History) Trainings(from, to time.Time) ([]TrainRecord, error) {
+	var trainings []TrainRecord
+	err := h.read("trainings.jsonl", func(dec func(v interface{}) error) error {
+		var r TrainRecord
+		if err := dec(&r); err != nil {
+			return err
+		}
+		if inRange(r.Time, from, to) {
+			trainings = append(trainings, r)
+		}
+		return nil
+	})
+	return trainings, err
+}
+
+// AddMeal appends a meal to the history.
+func (h *History) AddMeal(r MealRecord) error {
+	return h.append("meals.jsonl", r)
+}
+
+// Meals returns the meals eaten in [from, to).
+func (h *History) Meals(from, to time.Time) ([]MealRecord, error) {
+	var meals []MealRecord
+	err := h.read("meals.jsonl", func(dec func(v interface{}) error) error {
+		var r MealRecord
+		if err := dec(&r); err != nil {
+			return err
+		}
+		if inRange(r.Time, from, to) {
+			meals = append(meals, r)
+		}
+		return nil
+	})
+	return meals, err
+}
+
+func inRange(t, from, to time.Time) bool {
+	return (from.IsZero() || !t.Before(from)) && (to.IsZero() || t.Before(to))
+}
+
+func (h *History) append(name string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(h.Dir, 0777); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(filepath.Join(h.Dir, name), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0666)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// read calls each with a decoder for every line of the named file. A
+// missing file has no lines.
+func (h *History) read(name string, each func(dec func(v interface{}) error) error) error {
+	f, err := os.Open(filepath.Join(h.Dir, name))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	s := bufio.NewScanner(f)
+	s.Buffer(nil, 1<<20)
+	for line := 1; s.Scan(); line++ {
+		if len(s.Bytes()) == 0 {
+			continue
+		}
+		dec := func(v interface{}) error { return json.Unmarshal(s.Bytes(), v) }
+		if err := each(dec); err != nil {
+			return fmt.Errorf("%s line %d: %w", name, line, err)
+		}
+	}
+	return s.Err()
+}
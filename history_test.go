@@ -0,0 +1,49 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestHistoryBattles(t *testing.T) {
+	h := &History{Dir: filepath.Join(t.TempDir(), "history", "zippo")}
+	if battles, err := h.Battles(time.Time{}, time.Time{}); err != nil || len(battles) != 0 {
+		t.Fatalf("Battles of an empty history = %v, %v", battles, err)
+	}
+	start := time.Date(2011, 12, 14, 11, 0, 0, 0, time.UTC)
+	var want []BattleRecord
+	for i := 0; i < 3; i++ {
+		r := BattleRecord{
+			Start:    start.Add(time.Duration(i) * time.Hour),
+			End:      start.Add(time.Duration(i)*time.Hour + time.Minute),
+			BattleID: 100 + i,
+			Opponent: "Angry Bandit",
+			Rounds: []RoundRecord{
+				{Action: "Blunt Tipped Sabre", Hits: []BattleHit{{By: "zippo", Damage: 21}, {By: "Angry Bandit", Damage: 7}}},
+				{Action: "Blunt Tipped Sabre"},
+			},
+			Summary: BattleSummary{Outcome: outcomeWon, Exp: 12, Ryo: 30},
+		}
+		if err := h.AddBattle(r); err != nil {
+			t.Fatal("AddBattle:", err)
+		}
+		want = append(want, r)
+	}
+
+	got, err := h.Battles(time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatal("Battles:", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Battles = %+v, want %+v", got, want)
+	}
+	got, err = h.Battles(start.Add(time.Hour), start.Add(2*time.Hour))
+	if err != nil {
+		t.Fatal("Battles:", err)
+	}
+	if len(got) != 1 || got[0].BattleID != 101 {
+		t.Errorf("Battles in the second hour = %+v, want battle 101", got)
+	}
+}
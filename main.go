@@ -1,213 +1,287 @@
 package main
 
 import (
-	"goconf.googlecode.com/hg"
-	"rand"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"log"
+	"net/http"
 	"os"
-	"exec"
+	"path/filepath"
 	"strings"
+	"sync"
 	"time"
+
+	"gopkg.in/ini.v1"
 )
 
 const (
 	modeTrain = iota
 	modeBattle
+	modeErrands
+	modeAuto
 )
 
-var c *Client
-var configFile = flag.String("conf", "", "The filename holds the configuration to use.")
-var modestring = flag.String("mode", "train", "Choose between battle and train.")
+var modeNames = []string{
+	modeTrain:   "train",
+	modeBattle:  "battle",
+	modeErrands: "errands",
+	modeAuto:    "auto",
+}
+
+func parseMode(s string) (int, error) {
+	for mode, name := range modeNames {
+		if strings.EqualFold(s, name) {
+			return mode, nil
+		}
+	}
+	return 0, errors.New("Invalid mode \"" + s + "\"")
+}
+
+var configFiles = flag.String("conf", "", "The comma separated filenames in conf of the accounts to play.")
+var modestring = flag.String("mode", "train", "Choose between battle, train, errands and auto.")
 var psid = flag.String("psid", "", "A logged in PHPSESSID. Ninbot will use it instead of logging in.")
-var noPopup = flag.Bool("no-popup", false, "Don't popup the captcha webpage. Instead, print it's URL.")
+var baseURL = flag.String("url", DefaultBaseURL, "The address of the game server.")
+var record = flag.String("record", "", "A directory to save every downloaded page to, for harvesting test fixtures.")
+var captchaKind = flag.String("captcha", "browser", "How to hand the login captcha over: browser, stdin or web.")
+var captchaAddr = flag.String("captcha-addr", "localhost:8017", "The address the web captcha page is served on.")
+var apiAddr = flag.String("api", "", "An address to serve the status and control API on, such as localhost:8018.")
+var rate = flag.Float64("rate", 2, "The most requests per second to send the server, shared by all accounts.")
 
-var cnfName, cnfPass string
-var cnfRank int
-var cnfActionSeq, cnfStatSeq []string
-var cnfBattleRest, cnfTrainRest int
+var prompter CaptchaPrompter
 
-var mode int
+// config is the configuration of an account.
+type config struct {
+	Name, Pass                         string
+	Rank                               int
+	ActionSeq, StatSeq                 []string
+	Targets                            statTargets
+	Rules                              []rule
+	Strategy                           string
+	Explore                            float64
+	BattleRest, TrainRest, ErrandsRest int
+	TrainBatch                         int
+	HospitalPay                        bool
+	AutoHealth                         float32 // the least health percentage to battle with in auto mode
+	FoodPolicy                         string  // all or cheapest
+	FoodBudget                         int     // ryo to spend on food a day, or 0 for no limit
+	BankKeep                           int     // ryo to keep in pocket when depositing, or -1 to never deposit
+	BankInterval                       time.Duration
+	MaxFailures                        int
+}
 
-func loadConf() (err os.Error) {
-	cnf, err := conf.ReadConfigFile("conf\\" + *configFile)
+func loadConf(filename string) (cnf config, err error) {
+	file, err := ini.LoadSources(ini.LoadOptions{AllowShadows: true}, filepath.Join("conf", filename))
 	if err != nil {
 		return
 	}
-	cnfName, err = cnf.GetString("account", "name")
+	cnf.Name, err = confString(file, "account", "name")
 	if err != nil {
 		return
 	}
-	cnfPass, err = cnf.GetString("account", "password")
+	cnf.Pass, err = confString(file, "account", "password")
 	if err != nil {
 		return
 	}
-	rank, err := cnf.GetString("account", "rank")
+	cnf.Rank = rankUnknown
+	if name := file.Section("account").Key("rank").String(); name != "" {
+		cnf.Rank, err = ParseRank(name)
+		if err != nil {
+			return
+		}
+	}
+	actionSeq, err := confString(file, "battle", "sequence")
 	if err != nil {
 		return
 	}
-	switch strings.ToLower(rank) {
-	case "academy student":
-		cnfRank = rankAcademyStudent
-	case "genin":
-		cnfRank = rankGenin
-	case "chuunin":
-		cnfRank = rankChuunin
-	case "jounin":
-		cnfRank = rankJounin
-	case "special jounin":
-		cnfRank = rankSpecialJounin
-	default:
-		return os.NewError(fmt.Sprintf("Invalid rank \"%s\"", rank))
-	}
-	actionSeq, err := cnf.GetString("battle", "sequence")
+	cnf.ActionSeq = strings.Split(actionSeq, ",")
+	for i, s := range cnf.ActionSeq {
+		cnf.ActionSeq[i] = strings.TrimSpace(s)
+	}
+	if key, err := file.Section("battle").GetKey("rule"); err == nil {
+		for _, text := range key.ValueWithShadows() {
+			r, err := parseRule(text)
+			if err != nil {
+				return cnf, err
+			}
+			cnf.Rules = append(cnf.Rules, r)
+		}
+	}
+	cnf.Strategy = strings.ToLower(file.Section("battle").Key("strategy").MustString("sequence"))
+	if cnf.Strategy != "sequence" && cnf.Strategy != "adaptive" {
+		return cnf, errors.New("Invalid battle strategy \"" + cnf.Strategy + "\"")
+	}
+	cnf.Explore = file.Section("battle").Key("explore").MustFloat64(0.1)
+	if key, err := file.Section("train").GetKey("target"); err == nil {
+		cnf.Targets, err = parseStatTargets(key.ValueWithShadows())
+		if err != nil {
+			return cnf, err
+		}
+	}
+	// The sequence is only needed when there are no targets to train toward.
+	if len(cnf.Targets.stats) == 0 {
+		statSeq, err := confString(file, "train", "sequence")
+		if err != nil {
+			return cnf, err
+		}
+		cnf.StatSeq = strings.Split(statSeq, ",")
+		for i, s := range cnf.StatSeq {
+			cnf.StatSeq[i] = strings.TrimSpace(s)
+		}
+	}
+	cnf.BattleRest, err = confInt(file, "battle", "rest")
 	if err != nil {
 		return
 	}
-	cnfActionSeq = strings.Split(actionSeq, ",")
-	for i, s := range cnfActionSeq {
-		cnfActionSeq[i] = strings.TrimSpace(s)
-	}
-	statSeq, err := cnf.GetString("train", "sequence")
+	cnf.TrainRest, err = confInt(file, "train", "rest")
 	if err != nil {
 		return
 	}
-	cnfStatSeq = strings.Split(statSeq, ",")
-	for i, s := range cnfStatSeq {
-		cnfStatSeq[i] = strings.TrimSpace(s)
+	cnf.TrainBatch = file.Section("train").Key("batch").MustInt(1)
+	cnf.HospitalPay = file.Section("hospital").Key("pay").MustBool(false)
+	cnf.FoodPolicy = strings.ToLower(file.Section("food").Key("policy").MustString("all"))
+	if cnf.FoodPolicy != "all" && cnf.FoodPolicy != "cheapest" {
+		return cnf, errors.New("Invalid food policy \"" + cnf.FoodPolicy + "\"")
 	}
-	cnfBattleRest, err = cnf.GetInt("battle", "rest")
-	cnfTrainRest, err = cnf.GetInt("train", "rest")
+	cnf.FoodBudget = file.Section("food").Key("budget").MustInt(0)
+	cnf.BankKeep = file.Section("bank").Key("keep").MustInt(-1)
+	cnf.BankInterval = time.Duration(file.Section("bank").Key("interval").MustInt(0)) * time.Minute
+	cnf.AutoHealth = float32(file.Section("auto").Key("battle-health").MustFloat64(80))
+	cnf.MaxFailures = file.Section("supervisor").Key("max-failures").MustInt(5)
+	// The errands section is optional and rests as long as training does.
+	cnf.ErrandsRest = file.Section("errands").Key("rest").MustInt(cnf.TrainRest)
 	return
 }
 
-func main() {
-	flag.Parse()
-	c = NewClient()
+// confString returns the value of an option, failing if the option is missing.
+func confString(cnf *ini.File, section, option string) (string, error) {
+	key, err := cnf.Section(section).GetKey(option)
+	if err != nil {
+		return "", errors.New("option \"" + option + "\" not found in section \"" + section + "\"")
+	}
+	return key.String(), nil
+}
 
-	err := loadConf()
+func confInt(cnf *ini.File, section, option string) (int, error) {
+	key, err := cnf.Section(section).GetKey(option)
 	if err != nil {
-		log.Fatalf("Could not load configuration file \"%s\": %s\n", *configFile, err)
+		return 0, errors.New("option \"" + option + "\" not found in section \"" + section + "\"")
 	}
-	log.Printf("Ninbot is running with configuration \"%s\"\n", *configFile)
+	return key.Int()
+}
+
+// statusInterval is how often the status of every account is printed
+// when playing several.
+const statusInterval = time.Minute
+
+func main() {
+	flag.Parse()
 
-	switch strings.ToLower(*modestring) {
-	case "train":
-		mode = modeTrain
-	case "battle":
-		mode = modeBattle
-	default:
-		log.Fatalf("Invalid mode \"%s\"\n", *modestring)
+	var cnfs []config
+	for _, filename := range strings.Split(*configFiles, ",") {
+		filename = strings.TrimSpace(filename)
+		cnf, err := loadConf(filename)
+		if err != nil {
+			log.Fatalf("Could not load configuration file \"%s\": %s\n", filename, err)
+		}
+		cnfs = append(cnfs, cnf)
+	}
+	if flag.Arg(0) == "report" {
+		for i, cnf := range cnfs {
+			if len(cnfs) > 1 {
+				if i > 0 {
+					fmt.Println()
+				}
+				fmt.Printf("%s\n\n", cnf.Name)
+			}
+			if err := runReport(cnf, flag.Args()[1:]); err != nil {
+				log.Fatalln(err)
+			}
+		}
+		return
+	}
+	log.Printf("Ninbot is running with configuration \"%s\"\n", *configFiles)
+	if *psid != "" && len(cnfs) > 1 {
+		log.Fatalln("-psid can't be used with several accounts")
 	}
 
-	filename := time.LocalTime().Format("01.02.2006 15.04 05.000")
-	f, err := os.OpenFile("log\\"+filename, os.O_WRONLY|os.O_CREATE, 666)
+	mode, err := parseMode(*modestring)
 	if err != nil {
-		log.Fatalln("Can't open the log file: ", err)
+		log.Fatalln(err)
+	}
+	prompter, err = newCaptchaPrompter(*captchaKind, *captchaAddr)
+	if err != nil {
+		log.Fatalln(err)
 	}
-	log.SetOutput(io.MultiWriter(os.Stdout, f))
 	log.SetFlags(log.Ltime)
-
-	if *psid != "" {
-		c.PSID = *psid
-		c.LoggedIn = true
-	} else {
-		log.Println("Ninbot is logging in")
-		url, err := c.CaptchaURL(cnfName, cnfPass)
-		if err != nil {
-			log.Fatalln("Can't get captcha URL")
+	if *record != "" {
+		if err := os.MkdirAll(*record, 0777); err != nil {
+			log.Fatalln("Can't create the record directory:", err)
 		}
-		if *noPopup {
-			log.Println("Open the following url, solve it and paste the resulting code:", url)
-		} else {
-			log.Println("A captcha window will popup, solve it and paste the resulting code")
-			err = exec.Command("cmd", "/c", "start", url).Run()
-			if err != nil {
-				log.Fatalln("Can't open the captcha webpage with a browser:", err)
-			}
+	}
+
+	var budget *Budget
+	if *rate > 0 {
+		budget = &Budget{Interval: time.Duration(float64(time.Second) / *rate)}
+	}
+	timestamp := time.Now().Format("01.02.2006 15.04 05.000")
+	var bots []*bot
+	for _, cnf := range cnfs {
+		filename := timestamp
+		if len(cnfs) > 1 {
+			filename = cnf.Name + " " + timestamp
 		}
-		var code string
-		fmt.Scanln(&code)
-		success, err := c.Login(code, cnfName, cnfPass)
+		f, err := os.OpenFile(filepath.Join("log", filename), os.O_WRONLY|os.O_CREATE, 0666)
 		if err != nil {
-			log.Fatalln("Can't login:", err)
+			log.Fatalln("Can't open the log file: ", err)
 		}
-		if !success {
-			log.Fatalln("Name, password or captcha proof code are wrong.")
+		logger := log.New(io.MultiWriter(os.Stdout, f), "", log.Ltime)
+		if len(cnfs) > 1 {
+			logger.SetPrefix(cnf.Name + " ")
+			logger.SetFlags(log.Ltime | log.Lmsgprefix)
 		}
+		c := NewClient()
+		c.BaseURL = strings.TrimSuffix(*baseURL, "/")
+		c.RecordDir = *record
+		c.Budget = budget
+		bots = append(bots, newBot(c, cnf, logger, mode))
 	}
-	log.Printf("Logged in as %s with PHPSESSID = %s\n", cnfName, c.PSID)
 
-	switch mode {
-	case modeTrain:
-		var nstat int
-		for {
-			stat := cnfStatSeq[nstat]
-			res, err := c.Train(cnfRank, stat[1:], (stat[0] == '+'), -1)
-			if err != nil {
-				log.Fatalln("Can't train:", err)
-			}
-			log.Printf("Training improved %s by %f, now resting...\n", stat, res.GainStat)
-			time.Sleep(int64(cnfTrainRest)*1e9 + rand.Int63n(2e9))
-
-			nstat++
-			if nstat == len(cnfStatSeq) {
-				nstat = 0
-			}
-		}
+	if *apiAddr != "" {
+		go func() {
+			log.Fatalln("The API stopped:", http.ListenAndServe(*apiAddr, newAPI(bots)))
+		}()
+		log.Printf("Serving the API on http://%s/\n", *apiAddr)
+	}
 
-	case modeBattle:
-		var battles int
-		for {
-			log.Println("Entering battle...")
-			opponent, err := c.EnterBattle()
-			if err != nil {
-				log.Fatalln("Failed to enter battle:", err)
-			}
-			log.Printf("Fighting %s\n", opponent)
-			bg, err := c.Battleground()
-			if err != nil {
-				log.Fatalln("Failed to get battelground:", err)
+	var wg sync.WaitGroup
+	failed := make([]bool, len(bots))
+	for i, b := range bots {
+		wg.Add(1)
+		go func(i int, b *bot) {
+			defer wg.Done()
+			if err := b.run(); err != nil {
+				b.log.Println(err)
+				failed[i] = true
 			}
-			var action int
-			for {
-				round, err := bg.Attack(c, cnfActionSeq[action], opponent)
-				if err != nil {
-					if err == ErrBattleFinished {
-						break
-					}
-					log.Fatalln("Failed to attack:", err)
-				}
-				var s string
-				for _, hit := range round.Hits {
-					if hit.By == opponent {
-						s += fmt.Sprintf("\tHe hits %d\t", int(hit.Damage))
-					} else {
-						s += fmt.Sprintf("\tYou hit %d\t", int(hit.Damage))
-					}
-				}
-				log.Println(s)
-
-				action++
-				if action == len(cnfActionSeq) {
-					action = 0
+		}(i, b)
+	}
+	if len(bots) > 1 {
+		go func() {
+			for range time.Tick(statusInterval) {
+				var statuses []string
+				for _, b := range bots {
+					statuses = append(statuses, b.cnf.Name+": "+b.Status())
 				}
+				log.Println(strings.Join(statuses, " | "))
 			}
-			battles++
-			log.Printf("Battle number %d done\n", battles)
-			success, err := c.EatAll()
-			if err != nil {
-				log.Fatalln("Failed to eat all:", err)
-			}
-			if success {
-				log.Println("Ate all you can")
-			} else {
-				log.Println("Can't eat anymore")
-			}
-			log.Println("Resting a while...")
-			time.Sleep(int64(cnfBattleRest)*1e9 + rand.Int63n(2e9))
+		}()
+	}
+	wg.Wait()
+	for _, f := range failed {
+		if f {
+			os.Exit(1)
 		}
 	}
 }
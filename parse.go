@@ -1,16 +1,17 @@
 package main
 
 import (
+	"errors"
 	"regexp"
 	"strconv"
 	"strings"
-	"os"
 )
 
 const (
 	statusAwake = iota
 	statusBattle
 	statusAsleep
+	statusHospitalized
 )
 
 const (
@@ -21,6 +22,28 @@ const (
 	rankSpecialJounin
 )
 
+// rankUnknown is used when the rank is neither configured nor read yet.
+const rankUnknown = -1
+
+var rankNames = []string{
+	rankAcademyStudent: "Academy Student",
+	rankGenin:          "Genin",
+	rankChuunin:        "Chuunin",
+	rankJounin:         "Jounin",
+	rankSpecialJounin:  "Special Jounin",
+}
+
+// ParseRank returns the rank named s, as written in the profile or in
+// the configuration.
+func ParseRank(s string) (int, error) {
+	for rank, name := range rankNames {
+		if strings.EqualFold(strings.TrimSpace(s), name) {
+			return rank, nil
+		}
+	}
+	return rankUnknown, errors.New("Invalid rank \"" + s + "\"")
+}
+
 type Sidebar struct {
 	InBattle, Hospitalized bool
 	LogoutTimer            float32
@@ -72,11 +95,19 @@ type BattlePreparePage struct {
 
 type BattlegroundPage struct {
 	Sidebar
-	HealthChakraStamina
+	HealthChakraStamina // zero when the page doesn't show them
 	ID                  int
-	Actions             map[string]string
+	Actions             map[string]string // by actionKey
 	Opponents           map[string]int
-	YourActionSubmitted bool // whether the page contains "Your action has been submitted" message or not.
+	OpponentHealth      map[string]OpponentHealth // by lower case name, nil when the page doesn't show it
+	YourActionSubmitted bool                      // whether the page contains "Your action has been submitted" message or not.
+}
+
+// OpponentHealth is the health of an opponent in a battle.
+type OpponentHealth struct {
+	Health, MaxHealth float32 // MaxHealth is zero while the health was never shown nor estimated
+	Damage            float32 // dealt to the opponent since the battleground was read, filled by Battleground.Attack
+	Estimated         bool    // whether Health was estimated from Damage rather than shown, filled by Battleground.Attack and EstimateMaxHealth
 }
 
 type BattleHit struct {
@@ -86,7 +117,72 @@ type BattleHit struct {
 
 type BattleRoundPage struct {
 	Sidebar
-	Hits []BattleHit
+	HealthChakraStamina // zero when the page doesn't show them
+	Hits                []BattleHit
+	OpponentHealth      map[string]OpponentHealth // by lower case name, nil when the page doesn't show it
+}
+
+const (
+	outcomeUnknown = iota
+	outcomeWon
+	outcomeLost
+	outcomeDraw
+)
+
+var outcomeNames = []string{
+	outcomeUnknown: "unknown",
+	outcomeWon:     "won",
+	outcomeLost:    "lost",
+	outcomeDraw:    "draw",
+}
+
+type BattleSummaryPage struct {
+	Sidebar
+	Outcome           int
+	Exp, Ryo          int
+	Items             []string
+	Health, MaxHealth float32
+}
+
+type SleepPage struct {
+	Sidebar
+	Asleep bool
+}
+
+type ErrandsAmountSelectionPage struct {
+	Sidebar
+	MaxAmount int
+}
+
+type ErrandsResultPage struct {
+	Sidebar
+	MoneyEarned  int
+	SpentStamina float32
+}
+
+type HospitalPage struct {
+	Sidebar
+	Price int // ryo to pay for healing
+	Timer int // seconds until released
+}
+
+type RamenMenuPage struct {
+	Sidebar
+	Items []MenuItem
+}
+
+// MenuItem is an item of the ramen shop's menu.
+type MenuItem struct {
+	ID      int
+	Name    string
+	Restore float32 // health restored
+	Full    bool    // whether it restores all health
+	Price   int
+}
+
+type BankPage struct {
+	Sidebar
+	Money, BankedMoney int
 }
 
 type TrainAmountSelectionPage struct {
@@ -96,6 +192,7 @@ type TrainAmountSelectionPage struct {
 
 type TrainResultPage struct {
 	Sidebar
+	Amount                    int // the amount trained, filled by Client.Train
 	GainExp                   int
 	GainStat, GainChakra      float32
 	SpentChakra, SpentStamina float32
@@ -103,17 +200,17 @@ type TrainResultPage struct {
 
 var regexpLogoutTimer = regexp.MustCompile(`<b>Logout timer:</b>.+<noscript>([0-9]+) minutes (([0-9]+) seconds)*`)
 
-func ParseSidebar(input string) (b Sidebar, err os.Error) {
+func ParseSidebar(input string) (b Sidebar, err error) {
 	if strings.Contains(input, "<noscript>1 hour") {
 		b.LogoutTimer = 60
 	} else {
 		matches := regexpLogoutTimer.FindStringSubmatch(input)
 		if len(matches) != 4 {
-			return b, os.NewError("Failed to parse the logout timer in the sidebar")
+			return b, errors.New("Failed to parse the logout timer in the sidebar")
 		}
-		mins, _ := strconv.Atof32(matches[1])
-		secs, _ := strconv.Atof32(matches[3])
-		b.LogoutTimer = mins + (secs / 60)
+		mins, _ := strconv.ParseFloat(matches[1], 32)
+		secs, _ := strconv.ParseFloat(matches[3], 32)
+		b.LogoutTimer = float32(mins + (secs / 60))
 	}
 	b.InBattle = strings.Contains(input, `<a href="?id=41">In battle!</a>`)
 	b.Hospitalized = strings.Contains(input, `<a href="?id=34">Hospitalized!</a>`)
@@ -122,7 +219,7 @@ func ParseSidebar(input string) (b Sidebar, err os.Error) {
 
 var regexpCaptchaURL = regexp.MustCompile("<iframe src=\"([^\"]+)")
 
-func ParseLoginCaptchaPage(input string) (page LoginCaptchaPage, err os.Error) {
+func ParseLoginCaptchaPage(input string) (page LoginCaptchaPage, err error) {
 	matches := regexpCaptchaURL.FindStringSubmatch(input)
 	if len(matches) != 2 {
 		return
@@ -131,9 +228,187 @@ func ParseLoginCaptchaPage(input string) (page LoginCaptchaPage, err os.Error) {
 	return
 }
 
+var regexpHealthChakraStamina = regexp.MustCompile(`(?s)(Health|Chakra|Stamina):.*?([0-9\.]+) */ *([0-9\.]+)`)
+
+func ParseHealthChakraStamina(input string) (h HealthChakraStamina, err error) {
+	matches := regexpHealthChakraStamina.FindAllStringSubmatch(input, -1)
+	found := make(map[string]bool)
+	for _, match := range matches {
+		if found[match[1]] {
+			continue
+		}
+		found[match[1]] = true
+		cur, _ := strconv.ParseFloat(match[2], 32)
+		max, _ := strconv.ParseFloat(match[3], 32)
+		switch match[1] {
+		case "Health":
+			h.Health, h.MaxHealth = float32(cur), float32(max)
+		case "Chakra":
+			h.Chakra, h.MaxChakra = float32(cur), float32(max)
+		case "Stamina":
+			h.Stamina, h.MaxStamina = float32(cur), float32(max)
+		}
+	}
+	if len(found) != 3 {
+		err = errors.New("Failed to parse health, chakra and stamina")
+	}
+	return
+}
+
+// regexpField matches the label/value rows of the profile tables.
+var regexpField = regexp.MustCompile(`(?s)<b>([A-Za-z \.]+):</b>\s*</td>\s*<td[^>]*>\s*([^<]*)`)
+
+// parseFields returns the profile table rows keyed by their lowercased label.
+func parseFields(input string) map[string]string {
+	fields := make(map[string]string)
+	for _, match := range regexpField.FindAllStringSubmatch(input, -1) {
+		label := strings.ToLower(strings.TrimSpace(match[1]))
+		if _, ok := fields[label]; !ok {
+			fields[label] = strings.TrimSpace(match[2])
+		}
+	}
+	return fields
+}
+
+var regexpNumber = regexp.MustCompile(`[0-9][0-9,]*(\.[0-9]+)?`)
+
+// parseNumber parses the first number in s, ignoring thousands separators
+// and units such as "ryo".
+func parseNumber(s string) (float64, error) {
+	n := regexpNumber.FindString(s)
+	if n == "" {
+		return 0, errors.New("no number in \"" + s + "\"")
+	}
+	return strconv.ParseFloat(strings.Replace(n, ",", "", -1), 64)
+}
+
+// parseDuration parses a timer displayed either as "mm:ss" or as seconds.
+func parseDuration(s string) (secs int, err error) {
+	if i := strings.Index(s, ":"); i != -1 {
+		mins, err := strconv.Atoi(strings.TrimSpace(s[:i]))
+		if err != nil {
+			return 0, err
+		}
+		secs, err = strconv.Atoi(strings.TrimSpace(s[i+1:]))
+		return mins*60 + secs, err
+	}
+	n, err := parseNumber(s)
+	return int(n), err
+}
+
+func parseStatus(s string) (int, error) {
+	switch strings.ToLower(s) {
+	case "awake":
+		return statusAwake, nil
+	case "asleep":
+		return statusAsleep, nil
+	case "battle", "in battle":
+		return statusBattle, nil
+	case "hospitalized":
+		return statusHospitalized, nil
+	}
+	return 0, errors.New("Unknown status \"" + s + "\"")
+}
+
+func ParseProfilePage(input string) (page ProfilePage, err error) {
+	page.Sidebar, err = ParseSidebar(input)
+	if err != nil {
+		return
+	}
+	page.HealthChakraStamina, err = ParseHealthChakraStamina(input)
+	if err != nil {
+		return
+	}
+	fields := parseFields(input)
+	ints := []struct {
+		label string
+		dst   *int
+	}{
+		{"level", &page.Level},
+		{"money", &page.Money},
+		{"bank", &page.BankedMoney},
+		{"experience", &page.Experience},
+		{"exp needed", &page.NeededExperience},
+		{"pvp experience", &page.PVPExperience},
+	}
+	for _, f := range ints {
+		n, err := parseNumber(fields[f.label])
+		if err != nil {
+			return page, errors.New("Failed to parse profile page: " + f.label + ": " + err.Error())
+		}
+		*f.dst = int(n)
+	}
+	page.Rank = fields["rank"]
+	if page.Rank == "" {
+		err = errors.New("Failed to parse profile page: no rank found")
+		return
+	}
+	if anbu := fields["anbu"]; anbu != "" && strings.ToLower(anbu) != "none" {
+		page.ANBU = true
+	}
+	if clan := fields["clan"]; strings.ToLower(clan) != "none" {
+		page.Clan = clan
+	}
+	page.Status, err = parseStatus(fields["status"])
+	if err != nil {
+		return
+	}
+	rate, err := parseNumber(fields["regen rate"])
+	if err != nil {
+		err = errors.New("Failed to parse profile page: regen rate: " + err.Error())
+		return
+	}
+	page.RegenRate = float32(rate)
+	page.RegenTimer, err = parseDuration(fields["regen timer"])
+	if err != nil {
+		err = errors.New("Failed to parse profile page: regen timer: " + err.Error())
+	}
+	return
+}
+
+func ParseProfileWithStatsPage(input string) (page ProfileWithStatsPage, err error) {
+	page.ProfilePage, err = ParseProfilePage(input)
+	if err != nil {
+		return
+	}
+	fields := parseFields(input)
+	page.Name = fields["name"]
+	if page.Name == "" {
+		err = errors.New("Failed to parse profile page: no name found")
+		return
+	}
+	page.Gender = fields["gender"]
+	page.Email = fields["email"]
+	stats := []struct {
+		label string
+		dst   *float32
+	}{
+		{"taijutsu offense", &page.TaiStr},
+		{"ninjutsu offense", &page.NinStr},
+		{"genjutsu offense", &page.GenStr},
+		{"weapon offense", &page.WeapStr},
+		{"taijutsu defense", &page.TaiDef},
+		{"ninjutsu defense", &page.NinDef},
+		{"genjutsu defense", &page.GenDef},
+		{"weapon defense", &page.WeapDef},
+		{"strength", &page.Str},
+		{"intelligence", &page.Int},
+		{"speed", &page.Speed},
+		{"willpower", &page.Will},
+	}
+	for _, stat := range stats {
+		n, err := parseNumber(fields[stat.label])
+		if err != nil {
+			return page, errors.New("Failed to parse profile page: " + stat.label + ": " + err.Error())
+		}
+		*stat.dst = float32(n)
+	}
+	return
+}
+
 var regexpBattleEntrance = regexp.MustCompile(`<a href="(\?id=35&act=[^"]+)"><img src=\.(/images/antibot/[^>]+)></a> <img src=\./images/antibot/or\.gif> <a href="(\?id=35&act=[^"]+)"><img src=\.(/images/antibot/[^>]+)></a>`)
 
-func ParseBattleEntrancePage(input string) (page BattleEntrancePage, err os.Error) {
+func ParseBattleEntrancePage(input string) (page BattleEntrancePage, err error) {
 	page.Sidebar, err = ParseSidebar(input)
 	if err != nil {
 		return
@@ -151,14 +426,14 @@ func ParseBattleEntrancePage(input string) (page BattleEntrancePage, err os.Erro
 
 var regexpOpponentName = regexp.MustCompile(`<td align="center" style="font-weight:bold;">([^<]+)</td>`)
 
-func ParseBattlePreparePage(input string) (page BattlePreparePage, err os.Error) {
+func ParseBattlePreparePage(input string) (page BattlePreparePage, err error) {
 	page.Sidebar, err = ParseSidebar(input)
 	if err != nil {
 		return
 	}
 	matches := regexpOpponentName.FindStringSubmatch(input)
 	if len(matches) != 2 {
-		return page, os.NewError("Failed to parse battle prepare page")
+		return page, errors.New("Failed to parse battle prepare page")
 	}
 	page.OpponentName = matches[1]
 	return
@@ -168,7 +443,14 @@ var regexpBattleID = regexp.MustCompile(`<input type="hidden" name="battle_id" v
 var regexpAction = regexp.MustCompile(`<input name="action" type="radio" value="([^"]+)" (Checked)?> ([^<]+)`)
 var regexpOpponent = regexp.MustCompile(`input name="opponent" type="radio" value="([0-9]+)" (Checked)?> ([^<]+)`)
 
-func ParseBattlegroundPage(input string) (page BattlegroundPage, err os.Error) {
+// actionKey returns the name an action is known by in the battleground's
+// actions, the battle history and the learned estimates, whatever case it
+// is written in.
+func actionKey(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+func ParseBattlegroundPage(input string) (page BattlegroundPage, err error) {
 	page.Sidebar, err = ParseSidebar(input)
 	if err != nil {
 		return
@@ -181,25 +463,24 @@ func ParseBattlegroundPage(input string) (page BattlegroundPage, err os.Error) {
 
 	matches := regexpBattleID.FindStringSubmatch(input)
 	if len(matches) != 2 {
-		err = os.NewError("Failed to parse battleground page: couldn't parse battle ID")
+		err = errors.New("Failed to parse battleground page: couldn't parse battle ID")
 		return
 	}
 	page.ID, _ = strconv.Atoi(matches[1])
 
 	actionMatches := regexpAction.FindAllStringSubmatch(input, -1)
 	if len(actionMatches) == 0 {
-		err = os.NewError("Failed to parse battleground page: no actions found")
+		err = errors.New("Failed to parse battleground page: no actions found")
 		return
 	}
 	page.Actions = make(map[string]string)
 	for _, match := range actionMatches {
-		name := strings.ToLower(strings.TrimSpace(match[3]))
-		page.Actions[name] = match[1]
+		page.Actions[actionKey(match[3])] = match[1]
 	}
 
 	opponentMatches := regexpOpponent.FindAllStringSubmatch(input, -1)
 	if len(opponentMatches) == 0 {
-		err = os.NewError("Failed to parse battleground page: no opponents found")
+		err = errors.New("Failed to parse battleground page: no opponents found")
 		return
 	}
 	page.Opponents = make(map[string]int)
@@ -211,34 +492,61 @@ func ParseBattlegroundPage(input string) (page BattlegroundPage, err os.Error) {
 		name := strings.ToLower(strings.TrimSpace(match[3]))
 		page.Opponents[name] = n
 	}
+	page.OpponentHealth = parseOpponentHealth(input)
+	if h, err := ParseHealthChakraStamina(input); err == nil {
+		page.HealthChakraStamina = h
+	}
 	return
 }
 
+var regexpOpponentHealth = regexp.MustCompile(`<i>([^<]+)</i> health: *([0-9\.]+) */ *([0-9\.]+)`)
+
+// parseOpponentHealth returns the health of the opponents shown on a
+// battle page by lower case name, or nil when none is shown.
+func parseOpponentHealth(input string) map[string]OpponentHealth {
+	matches := regexpOpponentHealth.FindAllStringSubmatch(input, -1)
+	if len(matches) == 0 {
+		return nil
+	}
+	health := make(map[string]OpponentHealth)
+	for _, match := range matches {
+		cur, _ := strconv.ParseFloat(match[2], 32)
+		max, _ := strconv.ParseFloat(match[3], 32)
+		health[strings.ToLower(strings.TrimSpace(match[1]))] = OpponentHealth{Health: float32(cur), MaxHealth: float32(max)}
+	}
+	return health
+}
+
 var regexpDeal = regexp.MustCompile(`<font color="#000080"><i>([^<]+)</i> deals ([0-9\.]+) [a-z]* *damage to <i>([^<]+)</i></font>`)
 
-func ParseBattleRoundPage(input string) (page BattleRoundPage, err os.Error) {
+func ParseBattleRoundPage(input string) (page BattleRoundPage, err error) {
 	page.Sidebar, err = ParseSidebar(input)
 	if err != nil {
 		return
 	}
 	if !strings.Contains(input, `<td align="center" style="border-top:none;" class="subHeader">Outcome:</td>`) {
-		err = os.NewError("Failed to parse battle round page")
+		err = errors.New("Failed to parse battle round page")
 		return
 	}
+	if h, err := ParseHealthChakraStamina(input); err == nil {
+		page.HealthChakraStamina = h
+	}
 	matches := regexpDeal.FindAllStringSubmatch(input, -1)
 	if len(matches) == 0 {
-		err = os.NewError("Failed to parse battle round page: no damage deals were found")
+		err = errors.New("Failed to parse battle round page: no damage deals were found")
 		return
 	}
 	for _, match := range matches {
 		var hit BattleHit
-		hit.Damage, err = strconv.Atof32(match[2])
+		damage, err := strconv.ParseFloat(match[2], 32)
 		if err != nil {
-			return
+			return page, err
 		}
+		hit.Damage = float32(damage)
 		hit.By, hit.To = match[1], match[3]
 		page.Hits = append(page.Hits, hit)
 	}
+	page.OpponentHealth = parseOpponentHealth(input)
 	return
 }
 
@@ -246,44 +554,239 @@ func IsBattleSummaryPage(input string) bool {
 	return strings.Contains(input, `>Battle summary:</td>`)
 }
 
+var regexpBattleGain = regexp.MustCompile(`You gained ([0-9,]+) experience and ([0-9,]+) ryo`)
+var regexpBattleItem = regexp.MustCompile(`<i>([^<]+)</i>`)
+var regexpBattleItems = regexp.MustCompile(`You found (.+)\.`)
+
+// ParseBattleSummaryPage reads what it can of a battle summary. Since the
+// battle is over either way, missing details are left zero rather than
+// failing.
+func ParseBattleSummaryPage(input string) (page BattleSummaryPage, err error) {
+	page.Sidebar, err = ParseSidebar(input)
+	if err != nil {
+		return
+	}
+	switch {
+	case strings.Contains(input, "You have won the battle"):
+		page.Outcome = outcomeWon
+	case strings.Contains(input, "You have lost the battle"):
+		page.Outcome = outcomeLost
+	case strings.Contains(input, "ended in a draw"):
+		page.Outcome = outcomeDraw
+	}
+	if matches := regexpBattleGain.FindStringSubmatch(input); len(matches) == 3 {
+		exp, _ := parseNumber(matches[1])
+		ryo, _ := parseNumber(matches[2])
+		page.Exp, page.Ryo = int(exp), int(ryo)
+	}
+	if matches := regexpBattleItems.FindStringSubmatch(input); len(matches) == 2 {
+		for _, item := range regexpBattleItem.FindAllStringSubmatch(matches[1], -1) {
+			page.Items = append(page.Items, item[1])
+		}
+	}
+	for _, match := range regexpHealthChakraStamina.FindAllStringSubmatch(input, -1) {
+		if match[1] == "Health" {
+			health, _ := strconv.ParseFloat(match[2], 32)
+			max, _ := strconv.ParseFloat(match[3], 32)
+			page.Health, page.MaxHealth = float32(health), float32(max)
+			break
+		}
+	}
+	return
+}
+
+// IsLoggedOutPage tells whether the game answered with its login form
+// instead of the requested page.
+func IsLoggedOutPage(input string) bool {
+	return strings.Contains(input, `name="lgn_usr_stpd"`) && !strings.Contains(input, `<b>Logout timer:</b>`)
+}
+
 func IsMaintenancePage(input string) bool {
 	return strings.Contains(input, `Maintenance`)
 }
 
+func ParseSleepPage(input string) (page SleepPage, err error) {
+	page.Sidebar, err = ParseSidebar(input)
+	if err != nil {
+		return
+	}
+	switch {
+	case strings.Contains(input, `<a href="?id=16&act=wake">`):
+		page.Asleep = true
+	case strings.Contains(input, `<a href="?id=16&act=sleep">`):
+		page.Asleep = false
+	default:
+		err = errors.New("Failed to parse sleep page: no sleep or wake up link found")
+	}
+	return
+}
+
+var regexpHealPrice = regexp.MustCompile(`Healing costs ([0-9,]+) ryo`)
+var regexpReleaseTimer = regexp.MustCompile(`released in ([0-9:]+)`)
+
+func ParseHospitalPage(input string) (page HospitalPage, err error) {
+	page.Sidebar, err = ParseSidebar(input)
+	if err != nil || !page.Hospitalized {
+		return
+	}
+	matches := regexpReleaseTimer.FindStringSubmatch(input)
+	if len(matches) != 2 {
+		err = errors.New("Failed to parse hospital page: no release timer found")
+		return
+	}
+	page.Timer, err = parseDuration(matches[1])
+	if err != nil {
+		return
+	}
+	// Healing may not be offered, for example when it's free anyway.
+	if matches := regexpHealPrice.FindStringSubmatch(input); len(matches) == 2 {
+		price, _ := parseNumber(matches[1])
+		page.Price = int(price)
+	}
+	return
+}
+
+var regexpMenuItem = regexp.MustCompile(`<tr><td>([^<]+)</td><td>([^<]+)</td><td>([0-9,]+) ryo</td><td><a href="\?id=25&(?:amp;)?buy=([0-9]+)">`)
+var regexpRestore = regexp.MustCompile(`(?i)^\s*(all|[0-9\.]+) health`)
+
+func ParseRamenMenuPage(input string) (page RamenMenuPage, err error) {
+	page.Sidebar, err = ParseSidebar(input)
+	if err != nil {
+		return
+	}
+	for _, match := range regexpMenuItem.FindAllStringSubmatch(input, -1) {
+		item := MenuItem{Name: strings.TrimSpace(match[1])}
+		restore := regexpRestore.FindStringSubmatch(match[2])
+		if restore == nil {
+			return page, errors.New("Failed to parse ramen menu: can't understand what " + item.Name + " restores")
+		}
+		if strings.ToLower(restore[1]) == "all" {
+			item.Full = true
+		} else {
+			n, _ := strconv.ParseFloat(restore[1], 32)
+			item.Restore = float32(n)
+		}
+		price, _ := parseNumber(match[3])
+		item.Price = int(price)
+		item.ID, _ = strconv.Atoi(match[4])
+		page.Items = append(page.Items, item)
+	}
+	if len(page.Items) == 0 {
+		err = errors.New("Failed to parse ramen menu: no items found")
+	}
+	return
+}
+
+func ParseBankPage(input string) (page BankPage, err error) {
+	page.Sidebar, err = ParseSidebar(input)
+	if err != nil {
+		return
+	}
+	fields := parseFields(input)
+	money, err := parseNumber(fields["money"])
+	if err != nil {
+		return page, errors.New("Failed to parse bank page: money: " + err.Error())
+	}
+	banked, err := parseNumber(fields["bank"])
+	if err != nil {
+		return page, errors.New("Failed to parse bank page: bank: " + err.Error())
+	}
+	page.Money, page.BankedMoney = int(money), int(banked)
+	return
+}
+
 var regexpMaxAmount = regexp.MustCompile(`>([0-9]+)</option></select>`)
 
-func ParseTrainAmountSelectionPage(input string) (page TrainAmountSelectionPage, err os.Error) {
+func ParseTrainAmountSelectionPage(input string) (page TrainAmountSelectionPage, err error) {
 	page.Sidebar, err = ParseSidebar(input)
 	if err != nil {
 		return
 	}
+	// Without the chakra or stamina to train, the game offers no amount.
+	if tooTiredToTrain(input) || strings.Contains(input, `<select name="train_amount"></select>`) {
+		return
+	}
 	matches := regexpMaxAmount.FindStringSubmatch(input)
 	if len(matches) != 2 {
-		err = os.NewError("Failed to parse train amount selection page")
+		err = errors.New("Failed to parse train amount selection page")
 		return
 	}
 	page.MaxAmount, _ = strconv.Atoi(matches[1])
 	return
 }
 
+// tooTiredToTrain tells whether a training page refuses to train.
+func tooTiredToTrain(input string) bool {
+	return strings.Contains(input, "You are too tired to train.")
+}
+
 var regexpTrainResult = regexp.MustCompile(`You gained ([0-9]+) exp.+You improved ([0-9\.]+) points in`)
 
-func ParseTrainResultPage(input string) (page TrainResultPage, err os.Error) {
+var regexpTrainSpent = regexp.MustCompile(`spent ([0-9\.]+) chakra and ([0-9\.]+) stamina`)
+
+func ParseTrainResultPage(input string) (page TrainResultPage, err error) {
 	page.Sidebar, err = ParseSidebar(input)
 	if err != nil {
 		return
 	}
 	matches := regexpTrainResult.FindStringSubmatch(input)
 	if len(matches) != 3 {
-		err = os.NewError("Failed to parse train result page")
+		err = errors.New("Failed to parse train result page")
 		return
 	}
 	exp, _ := strconv.Atoi(matches[1])
-	stat, err := strconv.Atof32(matches[2])
+	stat, err := strconv.ParseFloat(matches[2], 32)
 	if err != nil {
 		return
 	}
 	page.GainExp = int(exp)
 	page.GainStat = float32(stat)
+
+	// Older result pages don't mention what the training cost.
+	if matches := regexpTrainSpent.FindStringSubmatch(input); len(matches) == 3 {
+		chakra, _ := strconv.ParseFloat(matches[1], 32)
+		stamina, _ := strconv.ParseFloat(matches[2], 32)
+		page.SpentChakra = float32(chakra)
+		page.SpentStamina = float32(stamina)
+	}
+	return
+}
+
+func ParseErrandsAmountSelectionPage(input string) (page ErrandsAmountSelectionPage, err error) {
+	page.Sidebar, err = ParseSidebar(input)
+	if err != nil {
+		return
+	}
+	matches := regexpMaxAmount.FindStringSubmatch(input)
+	if len(matches) != 2 {
+		err = errors.New("Failed to parse errands amount selection page")
+		return
+	}
+	page.MaxAmount, _ = strconv.Atoi(matches[1])
+	return
+}
+
+var regexpErrandsResult = regexp.MustCompile(`You earned ([0-9,]+) ryo.+You spent ([0-9\.]+) stamina`)
+
+func ParseErrandsResultPage(input string) (page ErrandsResultPage, err error) {
+	page.Sidebar, err = ParseSidebar(input)
+	if err != nil {
+		return
+	}
+	matches := regexpErrandsResult.FindStringSubmatch(input)
+	if len(matches) != 3 {
+		err = errors.New("Failed to parse errands result page")
+		return
+	}
+	money, err := parseNumber(matches[1])
+	if err != nil {
+		return
+	}
+	stamina, err := strconv.ParseFloat(matches[2], 32)
+	if err != nil {
+		return
+	}
+	page.MoneyEarned = int(money)
+	page.SpentStamina = float32(stamina)
 	return
 }
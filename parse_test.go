@@ -0,0 +1,152 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "Rewrite the golden files in testdata.")
+
+// parsers maps a fixture kind to the parser it exercises. A fixture's kind
+// is its filename up to the first dash, so testdata/battleground-submitted.html
+// is parsed by ParseBattlegroundPage. Pages saved with -record can be added
+// to the corpus by renaming them this way and running the tests with -update.
+var parsers = map[string]func(string) (interface{}, error){
+	"sidebar":        func(s string) (interface{}, error) { return ParseSidebar(s) },
+	"login":          func(s string) (interface{}, error) { return ParseLoginCaptchaPage(s) },
+	"profile":        func(s string) (interface{}, error) { return ParseProfileWithStatsPage(s) },
+	"battleentrance": func(s string) (interface{}, error) { return ParseBattleEntrancePage(s) },
+	"battleprepare":  func(s string) (interface{}, error) { return ParseBattlePreparePage(s) },
+	"battleground":   func(s string) (interface{}, error) { return ParseBattlegroundPage(s) },
+	"battleround":    func(s string) (interface{}, error) { return ParseBattleRoundPage(s) },
+	"battlesummary":  func(s string) (interface{}, error) { return ParseBattleSummaryPage(s) },
+	"maintenance":    func(s string) (interface{}, error) { return IsMaintenancePage(s), nil },
+	"errandsamount":  func(s string) (interface{}, error) { return ParseErrandsAmountSelectionPage(s) },
+	"errandsresult":  func(s string) (interface{}, error) { return ParseErrandsResultPage(s) },
+	"bank":           func(s string) (interface{}, error) { return ParseBankPage(s) },
+	"hospital":       func(s string) (interface{}, error) { return ParseHospitalPage(s) },
+	"ramen":          func(s string) (interface{}, error) { return ParseRamenMenuPage(s) },
+	"sleep":          func(s string) (interface{}, error) { return ParseSleepPage(s) },
+	"trainamount":    func(s string) (interface{}, error) { return ParseTrainAmountSelectionPage(s) },
+	"trainresult":    func(s string) (interface{}, error) { return ParseTrainResultPage(s) },
+}
+
+// golden is what a fixture is expected to parse into.
+type golden struct {
+	Page  interface{}
+	Error string `json:",omitempty"`
+}
+
+func TestParsers(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("testdata", "*.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Fatal("no fixtures found in testdata")
+	}
+	for _, file := range files {
+		name := strings.TrimSuffix(filepath.Base(file), ".html")
+		t.Run(name, func(t *testing.T) {
+			kind := strings.SplitN(name, "-", 2)[0]
+			parse, ok := parsers[kind]
+			if !ok {
+				t.Fatalf("no parser for fixture kind %q", kind)
+			}
+			input, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var g golden
+			g.Page, err = parse(string(input))
+			if err != nil {
+				g.Error = err.Error()
+			}
+			var buf bytes.Buffer
+			enc := json.NewEncoder(&buf)
+			enc.SetEscapeHTML(false)
+			enc.SetIndent("", "\t")
+			if err := enc.Encode(g); err != nil {
+				t.Fatal(err)
+			}
+			got := buf.Bytes()
+
+			goldenFile := strings.TrimSuffix(file, ".html") + ".golden"
+			if *update {
+				if err := os.WriteFile(goldenFile, got, 0666); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			want, err := os.ReadFile(goldenFile)
+			if err != nil {
+				t.Fatalf("%s (run with -update to create it)", err)
+			}
+			if string(got) != string(want) {
+				t.Errorf("parsed page differs from %s:\ngot:\n%s\nwant:\n%s", goldenFile, got, want)
+			}
+		})
+	}
+}
+
+func TestParseRank(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int
+		ok   bool
+	}{
+		{"Academy Student", rankAcademyStudent, true},
+		{"genin", rankGenin, true},
+		{" Chuunin ", rankChuunin, true},
+		{"SPECIAL JOUNIN", rankSpecialJounin, true},
+		{"Kage", rankUnknown, false},
+		{"", rankUnknown, false},
+	}
+	for _, test := range tests {
+		got, err := ParseRank(test.in)
+		if got != test.want || (err == nil) != test.ok {
+			t.Errorf("ParseRank(%q) = %d, %v; want %d, ok %v", test.in, got, err, test.want, test.ok)
+		}
+	}
+}
+
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int
+	}{
+		{"02:15", 135},
+		{"0:05", 5},
+		{"90", 90},
+		{"45 seconds", 45},
+	}
+	for _, test := range tests {
+		got, err := parseDuration(test.in)
+		if err != nil || got != test.want {
+			t.Errorf("parseDuration(%q) = %d, %v; want %d", test.in, got, err, test.want)
+		}
+	}
+}
+
+func TestIsLoggedOutPage(t *testing.T) {
+	tests := map[string]bool{
+		"sidebar-loggedout.html": true,
+		"sidebar-awake.html":     false,
+		"login-captcha.html":     false,
+		"maintenance.html":       false,
+	}
+	for file, want := range tests {
+		input, err := os.ReadFile(filepath.Join("testdata", file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := IsLoggedOutPage(string(input)); got != want {
+			t.Errorf("IsLoggedOutPage(%s) = %v, want %v", file, got, want)
+		}
+	}
+}
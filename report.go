@@ -0,0 +1,233 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// report summarises the history of an account over a time range.
+type report struct {
+	From, To  time.Time
+	Battles   int
+	Opponents []opponentStats // sorted by name
+	Actions   []actionStats   // sorted by name
+	Exp       int             // gained both in battles and in training
+	Stats     []statGain      // sorted by stat
+	Meals     int
+	FoodSpend int // on the meals of known cost
+	Unknown   int // meals of unknown cost
+}
+
+type opponentStats struct {
+	Name                     string
+	Battles, Won, Lost, Draw int
+}
+
+// actionStats is the damage of the rounds an action was chosen in. Rounds
+// without hits, such as the last round of a battle, aren't counted.
+type actionStats struct {
+	Name         string
+	Rounds       int
+	Dealt, Taken float32
+}
+
+type statGain struct {
+	Stat string
+	Gain float32
+}
+
+// buildReport summarises the given records. When from or to is zero, the
+// range starts at the first record or ends at the last one.
+func buildReport(battles []BattleRecord, trainings []TrainRecord, meals []MealRecord, from, to time.Time) (r report) {
+	r.From, r.To = from, to
+	span := func(start, end time.Time) {
+		if from.IsZero() && (r.From.IsZero() || start.Before(r.From)) {
+			r.From = start
+		}
+		if to.IsZero() && end.After(r.To) {
+			r.To = end
+		}
+	}
+
+	opponents := make(map[string]*opponentStats)
+	actions := make(map[string]*actionStats)
+	for _, b := range battles {
+		span(b.Start, b.End)
+		r.Battles++
+		r.Exp += b.Summary.Exp
+		// Resumed battles are recorded with the opponent in lower case.
+		key := strings.ToLower(b.Opponent)
+		o := opponents[key]
+		if o == nil {
+			o = &opponentStats{Name: b.Opponent}
+			opponents[key] = o
+		}
+		o.Battles++
+		switch b.Summary.Outcome {
+		case outcomeWon:
+			o.Won++
+		case outcomeLost:
+			o.Lost++
+		case outcomeDraw:
+			o.Draw++
+		}
+		for _, round := range b.Rounds {
+			if len(round.Hits) == 0 {
+				continue
+			}
+			name := actionKey(round.Action)
+			a := actions[name]
+			if a == nil {
+				a = &actionStats{Name: name}
+				actions[name] = a
+			}
+			dealt, taken := hitDamage(round.Hits, b.Opponent)
+			a.Rounds++
+			a.Dealt += dealt
+			a.Taken += taken
+		}
+	}
+	for _, o := range opponents {
+		r.Opponents = append(r.Opponents, *o)
+	}
+	sort.Slice(r.Opponents, func(i, j int) bool { return r.Opponents[i].Name < r.Opponents[j].Name })
+	for _, a := range actions {
+		r.Actions = append(r.Actions, *a)
+	}
+	sort.Slice(r.Actions, func(i, j int) bool { return r.Actions[i].Name < r.Actions[j].Name })
+
+	stats := make(map[string]float32)
+	for _, t := range trainings {
+		span(t.Time, t.Time)
+		r.Exp += t.Result.GainExp
+		stats[t.Stat] += t.Result.GainStat
+	}
+	for stat, gain := range stats {
+		r.Stats = append(r.Stats, statGain{Stat: stat, Gain: gain})
+	}
+	sort.Slice(r.Stats, func(i, j int) bool { return r.Stats[i].Stat < r.Stats[j].Stat })
+
+	for _, m := range meals {
+		span(m.Time, m.Time)
+		r.Meals++
+		if m.Spent == spentUnknown {
+			r.Unknown++
+			continue
+		}
+		r.FoodSpend += m.Spent
+	}
+	return
+}
+
+// Hours returns the length of the report's range in hours.
+func (r report) Hours() float64 {
+	return r.To.Sub(r.From).Hours()
+}
+
+// perHour formats an amount gained over the report's range per hour.
+func (r report) perHour(amount float64) string {
+	if r.Hours() <= 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%.2f/hour", amount/r.Hours())
+}
+
+// Write prints the report as tables.
+func (r report) Write(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	const layout = "2006-01-02 15:04"
+	if r.Battles == 0 && len(r.Stats) == 0 && r.Meals == 0 {
+		fmt.Fprintln(tw, "No history in this range.")
+		return tw.Flush()
+	}
+	fmt.Fprintf(tw, "From %s to %s (%.1f hours)\n", r.From.Format(layout), r.To.Format(layout), r.Hours())
+
+	fmt.Fprintf(tw, "\nBattles: %d\n", r.Battles)
+	if len(r.Opponents) > 0 {
+		fmt.Fprintln(tw, "Opponent\tBattles\tWon\tLost\tDraw\tWin rate")
+		for _, o := range r.Opponents {
+			fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%d\t%.0f%%\n", o.Name, o.Battles, o.Won, o.Lost, o.Draw, float64(o.Won)/float64(o.Battles)*100)
+		}
+	}
+	if len(r.Actions) > 0 {
+		fmt.Fprintln(tw, "\nAction\tRounds\tAvg dealt\tAvg taken")
+		for _, a := range r.Actions {
+			fmt.Fprintf(tw, "%s\t%d\t%.1f\t%.1f\n", a.Name, a.Rounds, a.Dealt/float32(a.Rounds), a.Taken/float32(a.Rounds))
+		}
+	}
+
+	fmt.Fprintf(tw, "\nExperience: %d (%s)\n", r.Exp, r.perHour(float64(r.Exp)))
+	if len(r.Stats) > 0 {
+		fmt.Fprintln(tw, "Stat\tGain\tPer hour")
+		for _, s := range r.Stats {
+			fmt.Fprintf(tw, "%s\t%.2f\t%s\n", s.Stat, s.Gain, r.perHour(float64(s.Gain)))
+		}
+	}
+
+	fmt.Fprintf(tw, "\nFood: %d meals for %d ryo", r.Meals, r.FoodSpend)
+	if r.Unknown > 0 {
+		fmt.Fprintf(tw, ", %d of unknown cost", r.Unknown)
+	}
+	fmt.Fprintln(tw)
+	return tw.Flush()
+}
+
+// parseReportTime parses a date, optionally with a time, in local time.
+func parseReportTime(s string) (time.Time, error) {
+	for _, layout := range []string{"2006-01-02 15:04", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, errors.New("Invalid time \"" + s + "\", expected YYYY-MM-DD or \"YYYY-MM-DD HH:MM\"")
+}
+
+// runReport prints a report of an account's history. It is
+// run as "ninbot -conf <file> report [-since <duration> | -from <time> -to <time>]".
+func runReport(cnf config, args []string) error {
+	fs := flag.NewFlagSet("report", flag.ContinueOnError)
+	since := fs.Duration("since", 0, "Report the history of the last duration only, such as 24h.")
+	fromString := fs.String("from", "", "Report the history from this date (YYYY-MM-DD or \"YYYY-MM-DD HH:MM\").")
+	toString := fs.String("to", "", "Report the history until this date.")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	var from, to time.Time
+	var err error
+	if *since > 0 {
+		to = time.Now()
+		from = to.Add(-*since)
+	}
+	if *fromString != "" {
+		if from, err = parseReportTime(*fromString); err != nil {
+			return err
+		}
+	}
+	if *toString != "" {
+		if to, err = parseReportTime(*toString); err != nil {
+			return err
+		}
+	}
+
+	h := &History{Dir: historyDir(cnf.Name)}
+	battles, err := h.Battles(from, to)
+	if err != nil {
+		return fmt.Errorf("Can't read battles: %w", err)
+	}
+	trainings, err := h.Trainings(from, to)
+	if err != nil {
+		return fmt.Errorf("Can't read trainings: %w", err)
+	}
+	meals, err := h.Meals(from, to)
+	if err != nil {
+		return fmt.Errorf("Can't read meals: %w", err)
+	}
+	return buildReport(battles, trainings, meals, from, to).Write(os.Stdout)
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBuildReport(t *testing.T) {
+	start := time.Date(2011, 12, 14, 10, 0, 0, 0, time.UTC)
+	battles := []BattleRecord{
+		{
+			Start: start, End: start.Add(time.Minute), Opponent: "Angry Bandit",
+			Rounds: []RoundRecord{
+				{Action: "Sabre", Hits: []BattleHit{{By: "zippo", Damage: 20}, {By: "Angry Bandit", Damage: 6}}},
+				{Action: "Punch", Hits: []BattleHit{{By: "zippo", Damage: 5}, {By: "angry bandit", Damage: 8}}},
+				{Action: "Sabre"},
+			},
+			Summary: BattleSummary{Outcome: outcomeWon, Exp: 10},
+		},
+		{
+			Start: start.Add(time.Hour), End: start.Add(time.Hour + time.Minute), Opponent: "angry bandit",
+			Rounds: []RoundRecord{
+				{Action: "sabre", Hits: []BattleHit{{By: "zippo", Damage: 10}, {By: "Angry Bandit", Damage: 30}}},
+			},
+			Summary: BattleSummary{Outcome: outcomeLost},
+		},
+		{
+			Start: start.Add(2 * time.Hour), End: start.Add(2*time.Hour + time.Minute), Opponent: "Wild Dog",
+			Summary: BattleSummary{Outcome: outcomeWon, Exp: 4},
+		},
+	}
+	trainings := []TrainRecord{
+		{Time: start.Add(30 * time.Minute), Stat: "+nin", Result: TrainResult{GainExp: 3, GainStat: 1.5}},
+		{Time: start.Add(90 * time.Minute), Stat: "+nin", Result: TrainResult{GainExp: 3, GainStat: 0.5}},
+		{Time: start.Add(4 * time.Hour), Stat: "-tai", Result: TrainResult{GainExp: 2, GainStat: 1}},
+	}
+	meals := []MealRecord{
+		{Time: start.Add(2 * time.Minute), Spent: 40},
+		{Time: start.Add(62 * time.Minute), Spent: 40},
+		{Time: start.Add(122 * time.Minute), Spent: spentUnknown},
+	}
+
+	r := buildReport(battles, trainings, meals, time.Time{}, time.Time{})
+	want := report{
+		From:    start,
+		To:      start.Add(4 * time.Hour),
+		Battles: 3,
+		Opponents: []opponentStats{
+			{Name: "Angry Bandit", Battles: 2, Won: 1, Lost: 1},
+			{Name: "Wild Dog", Battles: 1, Won: 1},
+		},
+		Actions: []actionStats{
+			{Name: "punch", Rounds: 1, Dealt: 5, Taken: 8},
+			{Name: "sabre", Rounds: 2, Dealt: 30, Taken: 36},
+		},
+		Exp:       22,
+		Stats:     []statGain{{Stat: "+nin", Gain: 2}, {Stat: "-tai", Gain: 1}},
+		Meals:     3,
+		FoodSpend: 80,
+		Unknown:   1,
+	}
+	if !reflect.DeepEqual(r, want) {
+		t.Errorf("buildReport = %+v, want %+v", r, want)
+	}
+
+	var buf bytes.Buffer
+	if err := r.Write(&buf); err != nil {
+		t.Fatal("Write:", err)
+	}
+	for _, s := range []string{"Experience: 22 (5.50/hour)", "Angry Bandit  2        1    1     0     50%", "Food: 3 meals for 80 ryo, 1 of unknown cost"} {
+		if !strings.Contains(buf.String(), s) {
+			t.Errorf("Report doesn't contain %q:\n%s", s, buf.String())
+		}
+	}
+
+	buf.Reset()
+	buildReport(nil, nil, nil, start, start.Add(time.Hour)).Write(&buf)
+	if !strings.Contains(buf.String(), "No history") {
+		t.Errorf("Report of an empty range = %q", buf.String())
+	}
+}
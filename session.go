@@ -0,0 +1,42 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Session is a login saved to disk, so the bot can resume it without
+// solving another captcha.
+type Session struct {
+	Account   string
+	PSID      string
+	LoginTime time.Time
+}
+
+// sessionFile returns where the session of an account is kept.
+func sessionFile(account string) string {
+	return filepath.Join("session", account+".json")
+}
+
+func LoadSession(filename string) (s Session, err error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return
+	}
+	err = json.Unmarshal(data, &s)
+	return
+}
+
+func (s Session) Save(filename string) error {
+	data, err := json.MarshalIndent(s, "", "\t")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(filename), 0777); err != nil {
+		return err
+	}
+	// The session is as good as the password, so keep it private.
+	return os.WriteFile(filename, data, 0600)
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSessionSaveLoad(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "session", "zippo.json")
+	want := Session{
+		Account:   "zippo",
+		PSID:      "k2j4h5g6f7d8s9a0",
+		LoginTime: time.Date(2011, 12, 14, 11, 5, 30, 0, time.UTC),
+	}
+	if err := want.Save(filename); err != nil {
+		t.Fatal("Save:", err)
+	}
+	got, err := LoadSession(filename)
+	if err != nil {
+		t.Fatal("LoadSession:", err)
+	}
+	if got.Account != want.Account || got.PSID != want.PSID || !got.LoginTime.Equal(want.LoginTime) {
+		t.Errorf("LoadSession = %+v, want %+v", got, want)
+	}
+	if _, err := LoadSession(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("LoadSession of a missing file succeeded")
+	}
+}
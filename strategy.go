@@ -0,0 +1,205 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// battleState is what a strategy knows about a battle when choosing the
+// action of a round.
+type battleState struct {
+	Opponent string
+	Round    int // the round about to be fought, starting at 1
+	HealthChakraStamina
+	LastAction     string      // the action of the previous round
+	LastHits       []BattleHit // the hits of the previous round
+	OpponentHealth OpponentHealth
+}
+
+// update records the action and outcome of a round.
+func (s *battleState) update(action string, round BattleRound) {
+	s.Round++
+	s.LastAction = action
+	s.LastHits = round.Hits
+	if round.MaxHealth > 0 {
+		s.HealthChakraStamina = round.HealthChakraStamina
+	}
+}
+
+// damage returns the damage dealt and taken in the previous round.
+func (s *battleState) damage() (dealt, taken float32) {
+	return hitDamage(s.LastHits, s.Opponent)
+}
+
+// hitDamage sums the damage of a round's hits dealt to and taken from
+// the opponent.
+func hitDamage(hits []BattleHit, opponent string) (dealt, taken float32) {
+	for _, hit := range hits {
+		if strings.EqualFold(hit.By, opponent) {
+			taken += hit.Damage
+		} else {
+			dealt += hit.Damage
+		}
+	}
+	return
+}
+
+// Strategy chooses the action of each round of a battle.
+type Strategy interface {
+	Action(bg *Battleground, s *battleState) string
+}
+
+// sequenceStrategy cycles through a fixed sequence of actions.
+type sequenceStrategy struct {
+	actions []string
+	next    int
+}
+
+func (st *sequenceStrategy) Action(bg *Battleground, s *battleState) string {
+	action := st.actions[st.next]
+	st.next = (st.next + 1) % len(st.actions)
+	return action
+}
+
+// ruleStrategy uses the action of the first rule whose conditions hold,
+// and asks the fallback strategy when none do.
+type ruleStrategy struct {
+	rules    []rule
+	fallback Strategy
+}
+
+func (st *ruleStrategy) Action(bg *Battleground, s *battleState) string {
+	for _, r := range st.rules {
+		if _, ok := bg.Actions[actionKey(r.action)]; !ok {
+			continue
+		}
+		if r.holds(s) {
+			return r.action
+		}
+	}
+	return st.fallback.Action(bg, s)
+}
+
+// newStrategy returns the configured strategy for a new battle.
+func (b *bot) newStrategy() Strategy {
+	var st Strategy = &sequenceStrategy{actions: b.cnf.ActionSeq}
+	if b.cnf.Strategy == "adaptive" {
+		st = &adaptiveStrategy{estimates: b.estimates, explore: b.cnf.Explore, fallback: st}
+	}
+	if len(b.cnf.Rules) > 0 {
+		st = &ruleStrategy{rules: b.cnf.Rules, fallback: st}
+	}
+	return st
+}
+
+// A rule is written as conditions joined by "and", an arrow and an action:
+//
+//	chakra < 20% -> Blunt Tipped Sabre
+//	health < 30% and round > 2 -> Heal Item
+//	opponent < 15% -> Attack
+//
+// Health, chakra and stamina compare either as points or as a percentage
+// of their maximum, and so does opponent, the health of the opponent as
+// shown or estimated, which never holds while it is unknown. Round is the
+// round about to be fought, and dealt and taken are the damage of the
+// previous round.
+type rule struct {
+	conds  []condition
+	action string
+}
+
+type condition struct {
+	variable string
+	op       string
+	value    float64
+	percent  bool
+}
+
+var regexpCondition = regexp.MustCompile(`^([a-z]+)\s*(<=|>=|!=|<|>|=)\s*([0-9]+(?:\.[0-9]+)?)(%?)$`)
+
+func parseRule(s string) (r rule, err error) {
+	i := strings.Index(s, "->")
+	if i == -1 {
+		return r, errors.New("Invalid rule \"" + s + "\": no \"->\" before the action")
+	}
+	r.action = strings.TrimSpace(s[i+2:])
+	if r.action == "" {
+		return r, errors.New("Invalid rule \"" + s + "\": no action")
+	}
+	for _, text := range strings.Split(strings.ToLower(s[:i]), " and ") {
+		matches := regexpCondition.FindStringSubmatch(strings.TrimSpace(text))
+		if matches == nil {
+			return r, errors.New("Invalid rule \"" + s + "\": can't understand \"" + strings.TrimSpace(text) + "\"")
+		}
+		cond := condition{variable: matches[1], op: matches[2], percent: matches[4] == "%"}
+		cond.value, _ = strconv.ParseFloat(matches[3], 64)
+		switch cond.variable {
+		case "health", "chakra", "stamina", "opponent":
+		case "round", "dealt", "taken":
+			if cond.percent {
+				return r, fmt.Errorf("Invalid rule \"%s\": %s can't be a percentage", s, cond.variable)
+			}
+		default:
+			return r, fmt.Errorf("Invalid rule \"%s\": unknown variable %s", s, cond.variable)
+		}
+		r.conds = append(r.conds, cond)
+	}
+	return r, nil
+}
+
+func (r rule) holds(s *battleState) bool {
+	for _, cond := range r.conds {
+		if !cond.holds(s) {
+			return false
+		}
+	}
+	return true
+}
+
+func (cond condition) holds(s *battleState) bool {
+	var cur, max float32
+	switch cond.variable {
+	case "health":
+		cur, max = s.Health, s.MaxHealth
+	case "chakra":
+		cur, max = s.Chakra, s.MaxChakra
+	case "stamina":
+		cur, max = s.Stamina, s.MaxStamina
+	case "opponent":
+		if s.OpponentHealth.MaxHealth <= 0 {
+			return false
+		}
+		cur, max = s.OpponentHealth.Health, s.OpponentHealth.MaxHealth
+	case "round":
+		cur = float32(s.Round)
+	case "dealt":
+		cur, _ = s.damage()
+	case "taken":
+		_, cur = s.damage()
+	}
+	v := float64(cur)
+	if cond.percent {
+		if max <= 0 {
+			return false
+		}
+		v = v / float64(max) * 100
+	}
+	switch cond.op {
+	case "<":
+		return v < cond.value
+	case "<=":
+		return v <= cond.value
+	case ">":
+		return v > cond.value
+	case ">=":
+		return v >= cond.value
+	case "=":
+		return v == cond.value
+	case "!=":
+		return v != cond.value
+	}
+	return false
+}
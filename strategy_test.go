@@ -0,0 +1,156 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func mustParseRules(t *testing.T, texts ...string) []rule {
+	var rules []rule
+	for _, text := range texts {
+		r, err := parseRule(text)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rules = append(rules, r)
+	}
+	return rules
+}
+
+func TestParseRule(t *testing.T) {
+	valid := []string{
+		"chakra < 20% -> Blunt Tipped Sabre",
+		"Health<=30.5 -> Heal Item",
+		"health < 30% and round > 2 and taken >= 10 -> Heal Item",
+		"round = 1 -> Thunder Roar",
+		"opponent <= 10 -> Attack",
+	}
+	for _, text := range valid {
+		if _, err := parseRule(text); err != nil {
+			t.Errorf("parseRule(%q): %v", text, err)
+		}
+	}
+	invalid := []string{
+		"chakra < 20%",
+		"chakra < 20% ->",
+		"luck > 5 -> Attack",
+		"round > 50% -> Attack",
+		"chakra is low -> Attack",
+	}
+	for _, text := range invalid {
+		if _, err := parseRule(text); err == nil {
+			t.Errorf("parseRule(%q) succeeded", text)
+		}
+	}
+}
+
+func TestRuleStrategy(t *testing.T) {
+	bg := &Battleground{Actions: map[string]string{
+		"clone technique":    "J12",
+		"wooden staff":       "W3",
+		"blunt tipped sabre": "W9",
+	}}
+	st := &ruleStrategy{
+		rules: mustParseRules(t,
+			"health < 30% -> Heal Item", // not available, skipped
+			"chakra < 20% -> Blunt Tipped Sabre",
+			"round = 1 -> Wooden Staff",
+			"taken > 20 and dealt < 10 -> Wooden Staff",
+			"opponent < 15% -> Wooden Staff",
+		),
+		fallback: &sequenceStrategy{actions: []string{"Clone Technique", "Attack"}},
+	}
+	state := &battleState{Opponent: "Wolf Cub", Round: 1}
+	state.Health, state.MaxHealth = 50, 200
+	state.Chakra, state.MaxChakra = 100, 120
+
+	tests := []struct {
+		setup func()
+		want  string
+	}{
+		{func() {}, "Wooden Staff"},
+		{func() { state.Round = 2 }, "Clone Technique"},
+		{func() { state.Chakra = 20 }, "Blunt Tipped Sabre"},
+		{func() {
+			state.Chakra = 100
+			state.LastHits = []BattleHit{{Damage: 25, By: "wolf cub", To: "zippo"}, {Damage: 5, By: "zippo", To: "Wolf Cub"}}
+		}, "Wooden Staff"},
+		{func() { state.LastHits = nil }, "Attack"},
+		{func() { state.OpponentHealth = OpponentHealth{Health: 8, MaxHealth: 80} }, "Wooden Staff"},
+		{func() { state.OpponentHealth.MaxHealth = 0 }, "Clone Technique"},
+	}
+	for i, test := range tests {
+		test.setup()
+		if got := st.Action(bg, state); got != test.want {
+			t.Errorf("round %d (#%d): Action = %q, want %q", state.Round, i, got, test.want)
+		}
+	}
+}
+
+func TestBattleStateUpdate(t *testing.T) {
+	state := battleState{Round: 1}
+	state.Health, state.MaxHealth = 200, 200
+
+	var round BattleRound
+	round.Hits = []BattleHit{{Damage: 7, By: "Wolf Cub", To: "zippo"}}
+	state.update("Wooden Staff", round)
+	if state.Round != 2 || state.Health != 200 {
+		t.Errorf("after a round without health, Round = %d and Health = %f", state.Round, state.Health)
+	}
+	round.Health, round.MaxHealth = 193, 200
+	state.update("Clone Technique", round)
+	if state.Round != 3 || state.Health != 193 || len(state.LastHits) != 1 || state.LastAction != "Clone Technique" {
+		t.Errorf("after a round, Round = %d, Health = %f and LastHits = %v", state.Round, state.Health, state.LastHits)
+	}
+}
+
+func TestAdaptiveStrategy(t *testing.T) {
+	bg := &Battleground{Actions: map[string]string{
+		"clone technique": "J12",
+		"wooden staff":    "W3",
+	}}
+	st := &adaptiveStrategy{estimates: make(ActionEstimates)}
+	state := &battleState{Opponent: "Wolf Cub", Round: 1}
+	play := func(dealt float32) string {
+		action := st.Action(bg, state)
+		var round BattleRound
+		round.Hits = []BattleHit{{Damage: dealt, By: "zippo", To: "Wolf Cub"}}
+		st.estimates.add(state.Opponent, action, dealt)
+		state.update(action, round)
+		return action
+	}
+
+	// Every action is tried once, then the one that dealt the most is kept.
+	if got := play(5); got != "clone technique" {
+		t.Errorf("first action = %q, want clone technique", got)
+	}
+	if got := play(20); got != "wooden staff" {
+		t.Errorf("second action = %q, want wooden staff", got)
+	}
+	for i := 0; i < 3; i++ {
+		if got := play(20); got != "wooden staff" {
+			t.Errorf("action %d = %q, want wooden staff", i+3, got)
+		}
+	}
+	if d := st.estimates["wolf cub"]["wooden staff"]; d.Rounds != 4 || d.Mean() != 20 {
+		t.Errorf("wooden staff estimate = %+v, want 4 rounds of 20", d)
+	}
+
+	// The estimates survive a restart.
+	filename := filepath.Join(t.TempDir(), "actions.json")
+	if err := st.estimates.Save(filename); err != nil {
+		t.Fatal("Save:", err)
+	}
+	loaded, err := LoadActionEstimates(filename)
+	if err != nil {
+		t.Fatal("LoadActionEstimates:", err)
+	}
+	if !reflect.DeepEqual(loaded, st.estimates) {
+		t.Errorf("LoadActionEstimates = %v, want %v", loaded, st.estimates)
+	}
+	st = &adaptiveStrategy{estimates: loaded}
+	if got := st.Action(bg, &battleState{Opponent: "Wolf Cub", Round: 1}); got != "wooden staff" {
+		t.Errorf("after a restart, action = %q, want wooden staff", got)
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"time"
+)
+
+// Kinds of errors a step can fail with, deciding how the supervisor
+// recovers from them.
+const (
+	failNetwork  = "network"
+	failSession  = "session"
+	failHospital = "hospital"
+	failBattle   = "battle"
+	failState    = "state"
+	failPage     = "page" // an unexpected page, usually one the parsers don't understand
+)
+
+// classify tells what kind of failure err is.
+func classify(err error) string {
+	var netErr net.Error
+	switch {
+	case errors.As(err, &netErr):
+		return failNetwork
+	case errors.Is(err, ErrNotLoggedIn), errors.Is(err, ErrSessionExpired):
+		return failSession
+	case errors.Is(err, ErrHospitalized):
+		return failHospital
+	case errors.Is(err, ErrBattleFinished), errors.Is(err, ErrNotInBattle):
+		return failBattle
+	case errors.Is(err, ErrNotAwake), errors.Is(err, ErrNotAsleep), errors.Is(err, ErrNotHospitalized):
+		return failState
+	}
+	return failPage
+}
+
+const (
+	minBackoff = 5 * time.Second
+	maxBackoff = 5 * time.Minute
+)
+
+// backoff returns how long to wait before retrying after the given
+// number of consecutive failures.
+func backoff(failures int) time.Duration {
+	d := minBackoff
+	for i := 1; i < failures && d < maxBackoff; i++ {
+		d *= 2
+	}
+	if d > maxBackoff {
+		d = maxBackoff
+	}
+	return d
+}
+
+// supervise runs step until the bot is stopped or the step fails with
+// errTargetsMet, waiting while the bot is paused. Failed steps are
+// retried with a growing backoff after the bot's state is re-synced with
+// the game, and supervise gives up after the configured number of
+// consecutive failures.
+func (b *bot) supervise(step func() error) error {
+	var failures int
+	for b.waitRunnable() {
+		b.clearWake()
+		err := step()
+		if err == nil {
+			failures = 0
+			continue
+		}
+		if errors.Is(err, errTargetsMet) {
+			b.log.Println(err)
+			return nil
+		}
+		if b.isStopped() {
+			break
+		}
+		if errors.Is(err, errInterrupted) {
+			continue
+		}
+		kind := classify(err)
+		failures++
+		if failures >= b.cnf.MaxFailures {
+			return fmt.Errorf("Giving up after %d failures in a row: %w", failures, err)
+		}
+		if kind == failSession {
+			b.log.Println(err)
+			if err := b.relogin(); err != nil {
+				b.log.Println("Failed to log in again:", err)
+			}
+			continue
+		}
+		if kind == failHospital {
+			b.log.Println(err)
+			if err := b.leaveHospital(); err != nil {
+				b.log.Println("Failed to leave the hospital:", err)
+			}
+			continue
+		}
+		wait := b.backoff(failures)
+		b.log.Printf("%s (%s error), retrying in %s\n", err, kind, wait)
+		if wait > 0 {
+			b.sleep(wait)
+		}
+		if _, err := b.c.Sync(); err != nil {
+			b.log.Println("Failed to sync state:", err)
+		} else if err := b.wakeUp(); err != nil {
+			b.log.Println(err)
+		}
+	}
+	return nil
+}
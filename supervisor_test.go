@@ -0,0 +1,100 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestClassify(t *testing.T) {
+	netErr := &url.Error{Op: "Get", URL: "http://www.theninja-rpg.com/?id=2", Err: timeoutError{}}
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{netErr, failNetwork},
+		{fmt.Errorf("Failed to enter battle: %w", netErr), failNetwork},
+		{ErrNotLoggedIn, failSession},
+		{fmt.Errorf("Failed to enter battle: %w", ErrHospitalized), failHospital},
+		{ErrNotInBattle, failBattle},
+		{ErrNotAwake, failState},
+		{errors.New("Failed to parse battle round page"), failPage},
+	}
+	for _, test := range tests {
+		if got := classify(test.err); got != test.want {
+			t.Errorf("classify(%q) = %s, want %s", test.err, got, test.want)
+		}
+	}
+}
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{1, 5 * time.Second},
+		{2, 10 * time.Second},
+		{4, 40 * time.Second},
+		{7, 5 * time.Minute},
+		{100, 5 * time.Minute},
+	}
+	for _, test := range tests {
+		if got := backoff(test.failures); got != test.want {
+			t.Errorf("backoff(%d) = %s, want %s", test.failures, got, test.want)
+		}
+	}
+}
+
+func TestSupervise(t *testing.T) {
+	errPage := errors.New("Failed to parse battle round page")
+	tests := []struct {
+		name    string
+		results []error // of the steps in order, the last repeating
+		stop    int     // the step that stops the bot, if any
+		calls   int
+		giveUp  bool
+	}{
+		{"gives up", []error{errPage}, 0, 3, true},
+		{"success resets the failures", []error{errPage, errPage, nil, errPage, errPage, nil, errTargetsMet}, 0, 7, false},
+		{"targets met", []error{errTargetsMet}, 0, 1, false},
+		{"stopped by a step", []error{nil}, 4, 4, false},
+		{"stopped by a failing step", []error{errPage}, 2, 2, false},
+		{"interruptions aren't failures", []error{errInterrupted, errInterrupted, errInterrupted, errInterrupted, errTargetsMet}, 0, 5, false},
+		{"hospital failures count", []error{fmt.Errorf("Failed to enter battle: %w", ErrHospitalized)}, 0, 3, true},
+	}
+	for _, test := range tests {
+		b := newBot(NewClient(), config{Name: "zippo", MaxFailures: 3}, log.New(io.Discard, "", 0), modeBattle)
+		b.backoff = func(int) time.Duration { return 0 }
+		var calls int
+		err := b.supervise(func() error {
+			calls++
+			if calls == test.stop {
+				b.Stop()
+			}
+			if calls > 10 {
+				b.Stop()
+				return nil
+			}
+			if calls > len(test.results) {
+				return test.results[len(test.results)-1]
+			}
+			return test.results[calls-1]
+		})
+		if (err != nil) != test.giveUp {
+			t.Errorf("%s: supervise = %v, want giving up %v", test.name, err, test.giveUp)
+		}
+		if calls != test.calls {
+			t.Errorf("%s: %d steps, want %d", test.name, calls, test.calls)
+		}
+	}
+}
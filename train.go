@@ -0,0 +1,341 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// regenInterval is how often the game regenerates health, chakra and stamina.
+const regenInterval = time.Minute
+
+// trainCost is the chakra and stamina a single training costs.
+type trainCost struct {
+	Chakra, Stamina float32
+}
+
+// costOf returns the cost of a single training from a training result,
+// and false when the result doesn't tell.
+func costOf(res TrainResult) (cost trainCost, ok bool) {
+	if res.Amount <= 0 || (res.SpentChakra <= 0 && res.SpentStamina <= 0) {
+		return cost, false
+	}
+	cost.Chakra = res.SpentChakra / float32(res.Amount)
+	cost.Stamina = res.SpentStamina / float32(res.Amount)
+	return cost, true
+}
+
+// regenTicks returns how many regenerations it takes for cur to reach
+// need, or max if need is more than max.
+func regenTicks(cur, need, max, rate float32) int {
+	if need > max {
+		need = max
+	}
+	if cur >= need {
+		return 0
+	}
+	return int(math.Ceil(float64((need - cur) / rate)))
+}
+
+// trainWait returns how long it takes until the character has the chakra
+// and stamina to train batch times. The profile's regen rate must be positive.
+func trainWait(p Profile, cost trainCost, batch int) time.Duration {
+	ticks := regenTicks(p.Chakra, cost.Chakra*float32(batch), p.MaxChakra, p.RegenRate)
+	if t := regenTicks(p.Stamina, cost.Stamina*float32(batch), p.MaxStamina, p.RegenRate); t > ticks {
+		ticks = t
+	}
+	if ticks == 0 {
+		return 0
+	}
+	return time.Duration(p.RegenTimer)*time.Second + time.Duration(ticks-1)*regenInterval
+}
+
+// trainGainPerHour projects the stat gained per hour when training is
+// only limited by how fast chakra and stamina regenerate.
+func trainGainPerHour(p Profile, res TrainResult, cost trainCost) float64 {
+	regen := float64(p.RegenRate) * float64(time.Hour/regenInterval)
+	trains := math.Inf(1)
+	if cost.Chakra > 0 {
+		trains = regen / float64(cost.Chakra)
+	}
+	if cost.Stamina > 0 {
+		trains = math.Min(trains, regen/float64(cost.Stamina))
+	}
+	return trains * float64(res.GainStat) / float64(res.Amount)
+}
+
+// restAfterTraining waits until the character regenerated enough to train
+// another batch, or for the configured rest when that can't be projected.
+func (b *bot) restAfterTraining(stat string, res TrainResult) error {
+	cost, ok := costOf(res)
+	if !ok {
+		b.log.Println("Training cost is unknown, resting...")
+		b.rest(time.Duration(b.cnf.TrainRest) * time.Second)
+		return nil
+	}
+	p, err := b.profile()
+	if err != nil {
+		return err
+	}
+	if p.RegenRate <= 0 {
+		b.log.Println("Not regenerating, resting...")
+		b.rest(time.Duration(b.cnf.TrainRest) * time.Second)
+		return nil
+	}
+	wait := trainWait(p, cost, b.cnf.TrainBatch)
+	b.log.Printf("Projected gain is %.2f %s per hour, resting %s...\n", trainGainPerHour(p, res, cost), stat, wait)
+	b.rest(wait)
+	return nil
+}
+
+// waitToTrain waits until the character has the chakra and stamina to
+// train a batch, as the last known cost of training tells. When the cost
+// isn't known yet, not even from the history, it waits the configured
+// rest if chakra or stamina is empty.
+func (b *bot) waitToTrain() error {
+	if b.cost == nil {
+		b.cost = b.historyTrainCost()
+	}
+	p, err := b.profile()
+	if err != nil {
+		return fmt.Errorf("Can't read profile: %w", err)
+	}
+	var wait time.Duration
+	switch {
+	case b.cost != nil && p.RegenRate > 0:
+		wait = trainWait(p, *b.cost, b.cnf.TrainBatch)
+	case p.Chakra <= 0 || p.Stamina <= 0:
+		wait = time.Duration(b.cnf.TrainRest) * time.Second
+	}
+	if wait <= 0 {
+		return nil
+	}
+	b.log.Printf("Waiting %s to regenerate before training...\n", wait)
+	b.setStatus("regenerating until %s", time.Now().Add(wait).Format("15:04:05"))
+	if !b.sleep(wait) {
+		return errInterrupted
+	}
+	return nil
+}
+
+// historyTrainCost returns the cost of the last training in the history
+// that tells it, or nil.
+func (b *bot) historyTrainCost() *trainCost {
+	trainings, err := b.history.Trainings(time.Time{}, time.Time{})
+	if err != nil {
+		b.log.Println("Can't read the trainings from the history:", err)
+		return nil
+	}
+	for i := len(trainings) - 1; i >= 0; i-- {
+		if cost, ok := costOf(trainings[i].Result); ok {
+			return &cost
+		}
+	}
+	return nil
+}
+
+// statTargets tells which stats to train and how far, as configured with
+// target options in the train section:
+//
+//	target = -tai 500
+//	target = +nin 2x +weap
+//
+// The first trains tai defense up to 500. The second keeps nin offense at
+// twice weap offense, training both; when weap offense has a target, nin
+// offense stops at twice that too. Ratios are resolved in order.
+type statTargets struct {
+	stats   []string           // in the order they were first mentioned
+	weights map[string]float32 // the stat's share relative to the others
+	caps    map[string]float32 // where to stop training the stat
+}
+
+var regexpStat = regexp.MustCompile(`^[+-](tai|nin|gen|weap)$`)
+
+func parseStatTargets(texts []string) (t statTargets, err error) {
+	t.weights = make(map[string]float32)
+	t.caps = make(map[string]float32)
+	add := func(stat string) error {
+		if !regexpStat.MatchString(stat) {
+			return errors.New("Invalid stat \"" + stat + "\", expected one of +tai, -tai, +nin, -nin, +gen, -gen, +weap or -weap")
+		}
+		if _, ok := t.weights[stat]; !ok {
+			t.stats = append(t.stats, stat)
+			t.weights[stat] = 1
+		}
+		return nil
+	}
+	type ratio struct {
+		stat, of string
+		times    float32
+	}
+	var ratios []ratio
+	for _, text := range texts {
+		fields := strings.Fields(strings.ToLower(text))
+		switch {
+		case len(fields) == 2:
+			if err = add(fields[0]); err != nil {
+				return
+			}
+			v, perr := strconv.ParseFloat(fields[1], 32)
+			if perr != nil || v <= 0 {
+				return t, errors.New("Invalid training target \"" + text + "\": " + fields[1] + " isn't a positive number")
+			}
+			t.caps[fields[0]] = float32(v)
+		case len(fields) == 3 && strings.HasSuffix(fields[1], "x"):
+			if err = add(fields[0]); err != nil {
+				return
+			}
+			if err = add(fields[2]); err != nil {
+				return
+			}
+			v, perr := strconv.ParseFloat(strings.TrimSuffix(fields[1], "x"), 32)
+			if perr != nil || v <= 0 {
+				return t, errors.New("Invalid training target \"" + text + "\": " + fields[1] + " isn't a positive ratio")
+			}
+			ratios = append(ratios, ratio{fields[0], fields[2], float32(v)})
+		default:
+			return t, errors.New("Invalid training target \"" + text + "\", expected \"<stat> <value>\" or \"<stat> <ratio>x <stat>\"")
+		}
+	}
+	for _, r := range ratios {
+		t.weights[r.stat] = r.times * t.weights[r.of]
+		if ofCap, ok := t.caps[r.of]; ok {
+			if cap, ok := t.caps[r.stat]; !ok || r.times*ofCap < cap {
+				t.caps[r.stat] = r.times * ofCap
+			}
+		}
+	}
+	return
+}
+
+// statValue returns the value of a stat, such as "+nin", on the profile.
+func statValue(p Profile, stat string) float32 {
+	offensive := stat[0] == '+'
+	switch stat[1:] {
+	case "tai":
+		if offensive {
+			return p.TaiStr
+		}
+		return p.TaiDef
+	case "nin":
+		if offensive {
+			return p.NinStr
+		}
+		return p.NinDef
+	case "gen":
+		if offensive {
+			return p.GenStr
+		}
+		return p.GenDef
+	case "weap":
+		if offensive {
+			return p.WeapStr
+		}
+		return p.WeapDef
+	}
+	return 0
+}
+
+// next returns the stat furthest behind its share among those that didn't
+// reach their target, and false when all did.
+func (t statTargets) next(p Profile) (stat string, ok bool) {
+	var behind float32
+	for _, s := range t.stats {
+		v := statValue(p, s)
+		if cap, capped := t.caps[s]; capped && v >= cap {
+			continue
+		}
+		if share := v / t.weights[s]; !ok || share < behind {
+			stat, behind, ok = s, share, true
+		}
+	}
+	return
+}
+
+// errTargetsMet is returned by the trainer's step when every training
+// target is met.
+var errTargetsMet = errors.New("All training targets are met")
+
+// nextStat returns the stat to train: the next of the sequence, or when
+// targets are configured, the one furthest behind.
+func (b *bot) nextStat() (string, error) {
+	if len(b.cnf.Targets.stats) == 0 {
+		stat := b.cnf.StatSeq[b.nstat]
+		b.nstat = (b.nstat + 1) % len(b.cnf.StatSeq)
+		return stat, nil
+	}
+	p, err := b.profile()
+	if err != nil {
+		return "", fmt.Errorf("Can't read profile: %w", err)
+	}
+	stat, ok := b.cnf.Targets.next(p)
+	if !ok {
+		return "", errTargetsMet
+	}
+	if cap, capped := b.cnf.Targets.caps[stat]; capped {
+		b.log.Printf("Training %s, %.2f of %.2f\n", stat, statValue(p, stat), cap)
+	} else {
+		b.log.Printf("Training %s, at %.2f\n", stat, statValue(p, stat))
+	}
+	return stat, nil
+}
+
+// trainer returns a step that trains the next stat and rests until the
+// one after it can be trained, or for the configured rest when the game
+// says the character is too tired. The step fails with errTargetsMet once
+// there is nothing left to train.
+func (b *bot) trainer() func() error {
+	return func() error {
+		stat, res, err := b.train()
+		if errors.Is(err, ErrTooTired) {
+			b.log.Println("Too tired to train, resting...")
+			b.rest(time.Duration(b.cnf.TrainRest) * time.Second)
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := b.restAfterTraining(stat, res); err != nil {
+			return fmt.Errorf("Can't read profile: %w", err)
+		}
+		return nil
+	}
+}
+
+// train waits until the character can train, and trains the next stat as
+// much as chakra and stamina allow.
+func (b *bot) train() (stat string, res TrainResult, err error) {
+	if time.Since(b.rankChecked) > rankCheckInterval {
+		if err = b.updateRank(); err != nil {
+			return stat, res, fmt.Errorf("Can't read rank: %w", err)
+		}
+	}
+	if err = b.waitToTrain(); err != nil {
+		return
+	}
+	stat, err = b.nextStat()
+	if err != nil {
+		return
+	}
+	b.setStatus("training %s", stat)
+	res, err = b.c.Train(b.rank, stat[1:], (stat[0] == '+'), -1)
+	if err != nil {
+		return stat, res, fmt.Errorf("Can't train: %w", err)
+	}
+	if cost, ok := costOf(res); ok {
+		b.cost = &cost
+	}
+	b.log.Printf("Training improved %s by %f\n", stat, res.GainStat)
+	record := TrainRecord{Time: time.Now(), Stat: stat, Result: res}
+	b.mu.Lock()
+	b.lastTrain = &record
+	b.mu.Unlock()
+	if err := b.history.AddTraining(record); err != nil {
+		b.log.Println("Can't save the training to the history:", err)
+	}
+	return
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"io"
+	"log"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestTrainWait(t *testing.T) {
+	var p Profile
+	p.Chakra, p.MaxChakra = 10, 50
+	p.Stamina, p.MaxStamina = 40, 50
+	p.RegenRate = 4
+	p.RegenTimer = 20
+	cost := trainCost{Chakra: 5, Stamina: 3}
+
+	tests := []struct {
+		batch int
+		want  time.Duration
+	}{
+		{1, 0},
+		{2, 0},
+		{3, 20*time.Second + regenInterval},   // 15 chakra: two regenerations
+		{6, 20*time.Second + 4*regenInterval}, // 30 chakra: five regenerations
+		{100, 20*time.Second + 9*regenInterval},
+	}
+	for _, test := range tests {
+		if got := trainWait(p, cost, test.batch); got != test.want {
+			t.Errorf("trainWait for a batch of %d = %s, want %s", test.batch, got, test.want)
+		}
+	}
+}
+
+func TestTrainGainPerHour(t *testing.T) {
+	var p Profile
+	p.RegenRate = 4
+	var res TrainResult
+	res.Amount, res.GainStat = 6, 0.3
+	res.SpentChakra, res.SpentStamina = 30, 18
+
+	cost, ok := costOf(res)
+	if !ok {
+		t.Fatal("costOf found no cost")
+	}
+	// 240 chakra regenerate per hour, enough for 48 trainings of 0.05.
+	if got := trainGainPerHour(p, res, cost); math.Abs(got-2.4) > 1e-4 {
+		t.Errorf("trainGainPerHour = %f, want 2.4", got)
+	}
+	if _, ok := costOf(TrainResult{Amount: 6, GainStat: 0.3}); ok {
+		t.Error("costOf found a cost in a result without one")
+	}
+}
+
+func TestStatTargets(t *testing.T) {
+	targets, err := parseStatTargets([]string{"-tai 500", "+nin 2x +weap", "+weap 100"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if targets.weights["+nin"] != 2 || targets.caps["+nin"] != 200 {
+		t.Errorf("+nin weight = %f and cap = %f, want 2 and 200", targets.weights["+nin"], targets.caps["+nin"])
+	}
+
+	var p Profile
+	tests := []struct {
+		setup func()
+		want  string
+	}{
+		{func() {}, "-tai"},
+		{func() { p.TaiDef, p.NinStr, p.WeapStr = 40, 30, 20 }, "+nin"}, // 30/2 < 20
+		{func() { p.NinStr = 50 }, "+weap"},
+		{func() { p.WeapStr = 30 }, "+nin"},
+		{func() { p.NinStr, p.WeapStr = 200, 100 }, "-tai"},
+		{func() { p.TaiDef = 500 }, ""},
+	}
+	for i, test := range tests {
+		test.setup()
+		got, ok := targets.next(p)
+		if got != test.want || ok != (test.want != "") {
+			t.Errorf("#%d: next = %q, %v, want %q", i, got, ok, test.want)
+		}
+	}
+
+	for _, text := range []string{"+luck 5", "-tai lots", "+nin twice +weap", "+nin 2x"} {
+		if _, err := parseStatTargets([]string{text}); err == nil {
+			t.Errorf("parseStatTargets(%q) succeeded", text)
+		}
+	}
+}
+
+func TestTrainerTooTired(t *testing.T) {
+	s := newFakeServer(t)
+	s.MaxTrain = 0
+	cnf := config{Name: s.Name, Rank: rankGenin, StatSeq: []string{"+nin"}, TrainBatch: 1}
+	b := newBot(s.loggedInClient(), cnf, log.New(io.Discard, "", 0), modeTrain)
+	b.history = &History{Dir: t.TempDir()}
+
+	if err := b.trainer()(); err != nil {
+		t.Fatal("Training too tired failed:", err)
+	}
+	if len(s.trained) != 0 {
+		t.Errorf("trained amounts %v, want none", s.trained)
+	}
+}